@@ -0,0 +1,178 @@
+package gincup
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrJWTClaimsMissing     = errors.New("claims not found in context")
+	ErrJWTInsufficientScope = errors.New("insufficient scope")
+	ErrJWTForbidden         = errors.New("forbidden")
+)
+
+// Policy decides whether the claims of a validated token are authorized.
+type Policy func(claims jwt.MapClaims) bool
+
+// HasScope is a policy that requires the scope in the "scope" claim,
+// a space-separated string or an array, or in the "scp" claim.
+func HasScope(scope string) Policy {
+	return func(claims jwt.MapClaims) bool {
+		return slices.Contains(scopesOf(claims), scope)
+	}
+}
+
+// HasRole is a policy that requires the role in the "roles" claim,
+// an array or a single string.
+func HasRole(role string) Policy {
+	return func(claims jwt.MapClaims) bool {
+		return slices.Contains(stringsClaim(claims, "roles"), role)
+	}
+}
+
+// AllOf is a policy that requires every policy.
+func AllOf(policies ...Policy) Policy {
+	return func(claims jwt.MapClaims) bool {
+		for _, p := range policies {
+			if !p(claims) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf is a policy that requires at least one of the policies.
+func AnyOf(policies ...Policy) Policy {
+	return func(claims jwt.MapClaims) bool {
+		for _, p := range policies {
+			if p(claims) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not is a policy that requires the policy to fail.
+func Not(policy Policy) Policy {
+	return func(claims jwt.MapClaims) bool {
+		return !policy(claims)
+	}
+}
+
+// RequireScopes is a middleware that requires every scope in the claims
+// of the validated token.
+//
+// It must be used after MiddlewareWithSubject or MiddlewareWithClaims.
+//
+// If the claims are not in the context, the middleware will return a 401 Unauthorized status.
+// If a scope is missing, the middleware will return a 403 Forbidden status.
+func (j *JWT) RequireScopes(scopes ...string) gin.HandlerFunc {
+	policies := make([]Policy, 0, len(scopes))
+	for _, scope := range scopes {
+		policies = append(policies, HasScope(scope))
+	}
+
+	return j.authorize(AllOf(policies...), &InsufficientScopeError{Scopes: scopes})
+}
+
+// RequireAnyRole is a middleware that requires at least one of the roles
+// in the claims of the validated token.
+//
+// It must be used after MiddlewareWithSubject or MiddlewareWithClaims.
+//
+// If the claims are not in the context, the middleware will return a 401 Unauthorized status.
+// If every role is missing, the middleware will return a 403 Forbidden status.
+func (j *JWT) RequireAnyRole(roles ...string) gin.HandlerFunc {
+	policies := make([]Policy, 0, len(roles))
+	for _, role := range roles {
+		policies = append(policies, HasRole(role))
+	}
+
+	return j.authorize(AnyOf(policies...), ErrJWTForbidden)
+}
+
+// RequirePolicy is a middleware that requires the policy to pass for the
+// claims of the validated token, for example:
+//
+//	j.RequirePolicy(AnyOf(HasRole("admin"), AllOf(HasRole("editor"), HasScope("posts:write"))))
+//
+// It must be used after MiddlewareWithSubject or MiddlewareWithClaims.
+//
+// If the claims are not in the context, the middleware will return a 401 Unauthorized status.
+// If the policy fails, the middleware will return a 403 Forbidden status.
+func (j *JWT) RequirePolicy(policy Policy) gin.HandlerFunc {
+	return j.authorize(policy, ErrJWTForbidden)
+}
+
+// authorize returns a middleware that rejects the request with err if the
+// policy fails.
+func (j *JWT) authorize(policy Policy, err error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := claimsFromGinContext(c)
+		if !ok {
+			j.abort(c, ErrJWTClaimsMissing)
+			return
+		}
+
+		if !policy(claims) {
+			j.forbid(c, err)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// forbid aborts the request with a 403 Forbidden status.
+func (j *JWT) forbid(c *gin.Context, err error) {
+	j.setChallenge(c, http.StatusForbidden, err)
+	j.respondError(c, http.StatusForbidden, err)
+}
+
+// claimsFromGinContext gets the claims of the validated token from the gin context.
+func claimsFromGinContext(c *gin.Context) (jwt.MapClaims, bool) {
+	v, ok := c.Get(claimsContextKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(jwt.MapClaims)
+	return claims, ok
+}
+
+// scopesOf returns the scopes of the "scope" and "scp" claims.
+func scopesOf(claims jwt.MapClaims) []string {
+	var scopes []string
+	for _, name := range []string{"scope", "scp"} {
+		if s, ok := claims[name].(string); ok {
+			scopes = append(scopes, strings.Fields(s)...)
+			continue
+		}
+		scopes = append(scopes, stringsClaim(claims, name)...)
+	}
+	return scopes
+}
+
+// stringsClaim returns a claim that is a string or an array of strings.
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,97 @@
+package gincup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicies(t *testing.T) {
+	claims := jwt.MapClaims{
+		"scope": "orders:read orders:write",
+		"scp":   []interface{}{"profile"},
+		"roles": []interface{}{"editor", "support"},
+	}
+
+	assert.True(t, HasScope("orders:read")(claims))
+	assert.True(t, HasScope("profile")(claims))
+	assert.False(t, HasScope("orders")(claims))
+	assert.True(t, HasRole("editor")(claims))
+	assert.False(t, HasRole("admin")(claims))
+	assert.True(t, HasRole("admin")(jwt.MapClaims{"roles": "admin"}))
+
+	assert.True(t, AllOf(HasRole("editor"), HasScope("orders:write"))(claims))
+	assert.False(t, AllOf(HasRole("editor"), HasRole("admin"))(claims))
+	assert.True(t, AnyOf(HasRole("admin"), HasRole("support"))(claims))
+	assert.False(t, AnyOf()(claims))
+	assert.True(t, Not(HasRole("admin"))(claims))
+}
+
+func TestJWTAuthorizationMiddlewares(t *testing.T) {
+	j := NewJWT("secret", 1*time.Hour)
+
+	router := gin.New()
+	ok := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+	authenticated := router.Group("/", j.MiddlewareWithSubject())
+	authenticated.GET("/orders", j.RequireScopes("orders:read"), ok)
+	authenticated.POST("/orders", j.RequireScopes("orders:read", "orders:write"), ok)
+	authenticated.GET("/admin", j.RequireAnyRole("admin", "owner"), ok)
+	authenticated.GET("/posts", j.RequirePolicy(AnyOf(HasRole("admin"), AllOf(HasRole("editor"), Not(HasScope("readonly"))))), ok)
+	router.GET("/unprotected", j.RequireScopes("orders:read"), ok)
+
+	request := func(method, path string, claims jwt.MapClaims) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if claims != nil {
+			token, err := GenerateTokenWithClaims(j, claims)
+			assert.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("scopes", func(t *testing.T) {
+		claims := jwt.MapClaims{"sub": "test123", "scope": "orders:read"}
+
+		w := request("GET", "/orders", claims)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = request("POST", "/orders", claims)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"message":"insufficient scope"}`, w.Body.String())
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		w := request("GET", "/admin", jwt.MapClaims{"sub": "test123", "roles": []string{"owner"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = request("GET", "/admin", jwt.MapClaims{"sub": "test123", "roles": []string{"user"}})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"message":"forbidden"}`, w.Body.String())
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		w := request("GET", "/posts", jwt.MapClaims{"sub": "test123", "roles": []string{"editor"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = request("GET", "/posts", jwt.MapClaims{"sub": "test123", "roles": []string{"editor"}, "scope": "readonly"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		w := request("GET", "/admin", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = request("GET", "/unprotected", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"unauthorized"}`, w.Body.String())
+	})
+}
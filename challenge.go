@@ -0,0 +1,126 @@
+package gincup
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InsufficientScopeError is the error of a request rejected by RequireScopes.
+type InsufficientScopeError struct {
+	// Scopes are the scopes required by the route.
+	Scopes []string
+}
+
+// Error implements the error interface.
+func (e *InsufficientScopeError) Error() string {
+	return ErrJWTInsufficientScope.Error()
+}
+
+// Is reports whether the target is ErrJWTInsufficientScope.
+func (e *InsufficientScopeError) Is(target error) bool {
+	return target == ErrJWTInsufficientScope
+}
+
+// setChallenge sets the RFC 6750 WWW-Authenticate header of a rejected request.
+func (j *JWT) setChallenge(c *gin.Context, status int, err error) {
+	j.setSchemeChallenge(c, "Bearer", status, err)
+}
+
+// setSchemeChallenge sets the WWW-Authenticate header of a rejected request
+// for the authentication scheme.
+//
+// Missing or malformed credentials get the "invalid_request" error, invalid,
+// expired or revoked tokens and expired sessions the "invalid_token" error,
+// tokens without the required scopes the "insufficient_scope" error, and
+// tokens of a too old or too weak authentication the RFC 9470
+// "insufficient_user_authentication" error. DPoP challenges also get the
+// "invalid_dpop_proof" error and the accepted proof algorithms.
+func (j *JWT) setSchemeChallenge(c *gin.Context, scheme string, status int, err error) {
+	params := [][2]string{}
+	if j.realm != "" {
+		params = append(params, [2]string{"realm", j.realm})
+	}
+
+	switch {
+	case status == http.StatusUnauthorized && errors.Is(err, ErrDPoPInvalidProof):
+		params = append(params,
+			[2]string{"error", "invalid_dpop_proof"},
+			[2]string{"error_description", ErrorMessage(status, err)},
+		)
+	case status == http.StatusUnauthorized && errors.Is(err, ErrJWTInsufficientUserAuthentication):
+		params = append(params,
+			[2]string{"error", "insufficient_user_authentication"},
+			[2]string{"error_description", ErrorMessage(status, err)},
+		)
+		var stepUpErr *InsufficientUserAuthenticationError
+		if errors.As(err, &stepUpErr) {
+			if len(stepUpErr.ACRValues) > 0 {
+				params = append(params, [2]string{"acr_values", strings.Join(stepUpErr.ACRValues, " ")})
+			}
+			if stepUpErr.MaxAge > 0 {
+				params = append(params, [2]string{"max_age", strconv.FormatInt(int64(stepUpErr.MaxAge/time.Second), 10)})
+			}
+		}
+	case status == http.StatusUnauthorized &&
+		(errors.Is(err, ErrJWTTokenNotFound) || errors.Is(err, ErrJWTInvalidAuthorizationHeader)):
+		params = append(params,
+			[2]string{"error", "invalid_request"},
+			[2]string{"error_description", ErrorMessage(status, err)},
+		)
+	case status == http.StatusUnauthorized &&
+		(errors.Is(err, ErrJWTInvalidToken) || errors.Is(err, ErrJWTTokenExpired) ||
+			errors.Is(err, ErrJWTTokenRevoked) || errors.Is(err, ErrJWTSessionExpired) ||
+			errors.Is(err, ErrDPoPKeyMismatch) || errors.Is(err, ErrDPoPBoundToken) ||
+			errors.Is(err, ErrJWTInvalidClaims) || errors.Is(err, ErrOIDCInvalidNonce) ||
+			errors.Is(err, ErrOIDCInvalidAuthorizedParty)):
+		params = append(params,
+			[2]string{"error", "invalid_token"},
+			[2]string{"error_description", ErrorMessage(status, err)},
+		)
+	case status == http.StatusForbidden && errors.Is(err, ErrJWTInsufficientScope):
+		params = append(params,
+			[2]string{"error", "insufficient_scope"},
+			[2]string{"error_description", ErrorMessage(status, err)},
+		)
+		var scopeErr *InsufficientScopeError
+		if errors.As(err, &scopeErr) && len(scopeErr.Scopes) > 0 {
+			params = append(params, [2]string{"scope", strings.Join(scopeErr.Scopes, " ")})
+		}
+	case status == http.StatusUnauthorized:
+	default:
+		// not an authentication error
+		return
+	}
+
+	if scheme == "DPoP" {
+		params = append(params, [2]string{"algs", strings.Join(dpopAlgorithms, " ")})
+	}
+	c.Header("WWW-Authenticate", formatChallenge(scheme, params))
+}
+
+// formatChallenge formats a challenge with quoted parameters.
+func formatChallenge(scheme string, params [][2]string) string {
+	if len(params) == 0 {
+		return scheme
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme)
+	for i, p := range params {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(p[0])
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(p[1]))
+		b.WriteString(`"`)
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package gincup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTChallenge(t *testing.T) {
+	j := NewJWT("secret", 1*time.Hour, WithRealm("example"))
+
+	router := gin.New()
+	router.GET("/test", j.MiddlewareWithSubject(), j.RequireScopes("orders:read", "orders:write"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/admin", j.MiddlewareWithSubject(), j.RequireAnyRole("admin"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/unprotected", j.RequireAnyRole("admin"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("missing token", func(t *testing.T) {
+		w := request("/test", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="example", error="invalid_request", error_description="invalid Authorization header format"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("malformed header", func(t *testing.T) {
+		w := request("/test", "Basic dXNlcjpwYXNz")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="example", error="invalid_request", error_description="invalid Authorization header format"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		w := request("/test", "Bearer invalid.token.here")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="example", error="invalid_token", error_description="invalid token"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		token, err := GenerateTokenWithClaims(j, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})
+		assert.NoError(t, err)
+
+		w := request("/test", "Bearer "+token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="example", error="invalid_token", error_description="token expired"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("insufficient scope", func(t *testing.T) {
+		token, err := GenerateTokenWithClaims(j, jwt.MapClaims{"sub": "test123", "scope": "orders:read"})
+		assert.NoError(t, err)
+
+		w := request("/test", "Bearer "+token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, `Bearer realm="example", error="insufficient_scope", error_description="insufficient scope", scope="orders:read orders:write"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("forbidden role", func(t *testing.T) {
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		w := request("/admin", "Bearer "+token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("no error code", func(t *testing.T) {
+		w := request("/unprotected", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="example"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("without realm", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour)
+
+		router := gin.New()
+		router.Use(j.Middleware())
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer invalid.token.here")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, `Bearer error="invalid_token", error_description="invalid token"`, w.Header().Get("WWW-Authenticate"))
+	})
+}
+
+func TestFormatChallenge(t *testing.T) {
+	assert.Equal(t, "Bearer", formatChallenge("Bearer", nil))
+	assert.Equal(t, `Bearer realm="say \"hi\" \\"`, formatChallenge("Bearer", [][2]string{{"realm", `say "hi" \`}}))
+}
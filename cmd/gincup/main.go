@@ -0,0 +1,566 @@
+// Command gincup mints, decodes and verifies the JWT tokens of gincup, and
+// hashes and verifies passwords, so that tokens never have to be pasted into
+// third-party websites.
+//
+// Usage:
+//
+//	gincup mint -secret <secret> | -key <private.pem> [-sub <subject>] [-claims <json>] [-exp <duration>] [-kid <kid>] [-iss <issuer>] [-aud <audience>]
+//	gincup decode [<token>]
+//	gincup verify -secret <secret> | -key <public.pem> | -jwks <jwks.json> [-alg <algorithms>] [-iss <issuer>] [-aud <audience>] [<token>]
+//	gincup hash
+//	gincup verify-password -hash <hash>
+//
+// Tokens are read from stdin when they are not given as an argument, and
+// passwords are always read from stdin. The secret may also be set with the
+// GINCUP_SECRET environment variable to keep it out of the shell history.
+//
+// With -jwks, the tokens must be signed with the "alg" of their key, as with
+// gincup.RemoteJWKS.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/okppop/gincup"
+)
+
+const usage = `usage: gincup <command> [flags]
+
+commands:
+  mint             mint a token
+  decode           decode a token without verifying it
+  verify           verify a token and print its claims
+  hash             hash a password read from stdin
+  verify-password  verify a password read from stdin against a hash
+
+run "gincup <command> -h" for the flags of a command
+`
+
+var (
+	errUsage       = errors.New("usage")
+	errKeyRequired = errors.New("one of -secret, -key or -jwks is required")
+	errInvalidPEM  = errors.New("no PEM block found")
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command and returns the exit code: 0 on success, 1 on
+// failure and 2 on usage errors.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "mint":
+		err = mint(args[1:], stdout, stderr)
+	case "decode":
+		err = decode(args[1:], stdin, stdout, stderr)
+	case "verify":
+		err = verify(args[1:], stdin, stdout, stderr)
+	case "hash":
+		err = hash(args[1:], stdin, stdout, stderr)
+	case "verify-password":
+		err = verifyPassword(args[1:], stdin, stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "gincup: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	default:
+		fmt.Fprintf(stderr, "gincup %s: %v\n", args[0], err)
+		return 1
+	}
+}
+
+// newFlagSet creates the flag set of a command.
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("gincup "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// parseFlags parses the flags of a command. Usage errors wrap errUsage.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// mint mints a token.
+func mint(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("mint", stderr)
+	secret := fs.String("secret", os.Getenv("GINCUP_SECRET"), "HMAC secret")
+	keyFile := fs.String("key", "", "PEM file of an RSA, ECDSA or Ed25519 private key")
+	sub := fs.String("sub", "", "subject")
+	claimsJSON := fs.String("claims", "", "additional claims as a JSON object")
+	exp := fs.Duration("exp", 1*time.Hour, "expire duration")
+	kid := fs.String("kid", "", "key id")
+	iss := fs.String("iss", "", "issuer")
+	aud := fs.String("aud", "", "audience")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *exp <= 0 {
+		return errors.New("-exp must be greater than 0")
+	}
+
+	claims := map[string]any{}
+	if *claimsJSON != "" {
+		decoder := json.NewDecoder(strings.NewReader(*claimsJSON))
+		decoder.UseNumber()
+		if err := decoder.Decode(&claims); err != nil {
+			return fmt.Errorf("invalid -claims: %w", err)
+		}
+	}
+	if *sub != "" {
+		claims["sub"] = *sub
+	}
+
+	var opts []gincup.JWTOption
+	if *iss != "" {
+		opts = append(opts, gincup.WithIssuer(*iss))
+	}
+	if *aud != "" {
+		opts = append(opts, gincup.WithAudience(*aud))
+	}
+
+	var j *gincup.JWT
+	var key any
+	switch {
+	case *keyFile != "":
+		privateKey, err := readPrivateKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		j = gincup.NewJWTWithPrivateKey(privateKey, *exp, opts...)
+		key = privateKey
+	case *secret != "":
+		j = gincup.NewJWT(*secret, *exp, opts...)
+		key = *secret
+	default:
+		return errors.New("one of -secret or -key is required")
+	}
+	if *kid != "" {
+		if err := j.RotateKey(*kid, key); err != nil {
+			return err
+		}
+	}
+
+	token, err := gincup.GenerateTokenWithClaims(j, claims)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, token)
+	return nil
+}
+
+// decode prints the header and the claims of a token without verifying it.
+func decode(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("decode", stderr)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	token, err := readToken(fs, stdin)
+	if err != nil {
+		return err
+	}
+
+	t, _, err := jwt.NewParser(jwt.WithJSONNumber()).ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return err
+	}
+	return printJSON(stdout, map[string]any{
+		"header": t.Header,
+		"claims": t.Claims,
+	})
+}
+
+// verify verifies a token and prints its claims.
+func verify(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("verify", stderr)
+	secret := fs.String("secret", os.Getenv("GINCUP_SECRET"), "HMAC secret")
+	keyFile := fs.String("key", "", "PEM file of a public key or certificate")
+	jwksFile := fs.String("jwks", "", "JSON Web Key Set file")
+	alg := fs.String("alg", "", "comma-separated algorithms accepted with -secret or -key, by default the algorithm of the key")
+	iss := fs.String("iss", "", "required issuer")
+	aud := fs.String("aud", "", "required audience")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	token, err := readToken(fs, stdin)
+	if err != nil {
+		return err
+	}
+
+	var opts []gincup.JWTOption
+	if *iss != "" {
+		opts = append(opts, gincup.WithIssuer(*iss))
+	}
+	if *aud != "" {
+		opts = append(opts, gincup.WithAudience(*aud))
+	}
+
+	var algs []string
+	if *alg != "" {
+		algs = strings.Split(*alg, ",")
+	}
+
+	var j *gincup.JWT
+	switch {
+	case *jwksFile != "":
+		if len(algs) > 0 {
+			return errors.New("-alg cannot be used with -jwks, the algorithms are those of the keys")
+		}
+		j, err = newJWKSVerifier(*jwksFile, opts)
+	case *keyFile != "":
+		var key crypto.PublicKey
+		if key, err = readPublicKey(*keyFile); err == nil {
+			if opts, err = allowAlgorithms(opts, key, algs); err == nil {
+				j = gincup.NewJWTVerifier(key, opts...)
+				err = addKeyForKeyID(j, token, key)
+			}
+		}
+	case *secret != "":
+		if opts, err = allowAlgorithms(opts, *secret, algs); err == nil {
+			// the expire duration is only used to mint tokens
+			j = gincup.NewJWT(*secret, time.Hour, opts...)
+			err = addKeyForKeyID(j, token, *secret)
+		}
+	default:
+		return errKeyRequired
+	}
+	if err != nil {
+		return err
+	}
+
+	claims, err := j.ParseToken(context.Background(), token)
+	if err != nil {
+		return err
+	}
+	return printJSON(stdout, claims)
+}
+
+// hash hashes the password read from stdin.
+func hash(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("hash", stderr)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	password, err := readLine(stdin)
+	if err != nil {
+		return err
+	}
+
+	hashed, err := gincup.BcryptHash(password)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, hashed)
+	return nil
+}
+
+// verifyPassword verifies the password read from stdin against a hash.
+func verifyPassword(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("verify-password", stderr)
+	hashed := fs.String("hash", "", "bcrypt hash")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	password, err := readLine(stdin)
+	if err != nil {
+		return err
+	}
+
+	if err := gincup.BcryptVerify(*hashed, password); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "ok")
+	return nil
+}
+
+// readToken reads the token from the first argument, or from stdin.
+func readToken(fs *flag.FlagSet, stdin io.Reader) (string, error) {
+	if fs.NArg() > 1 {
+		return "", errUsage
+	}
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		return fs.Arg(0), nil
+	}
+	return readLine(stdin)
+}
+
+// readLine reads the first line of r without the line ending.
+func readLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("no input on stdin")
+	}
+	return line, nil
+}
+
+// printJSON prints v as indented JSON.
+func printJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// addKeyForKeyID adds the key under the "kid" header of the token, so that
+// a single key verifies tokens with or without a key id.
+func addKeyForKeyID(j *gincup.JWT, token string, key any) error {
+	t, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return gincup.ErrJWTInvalidToken
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		return nil
+	}
+	return j.AddVerificationKey(kid, key)
+}
+
+// allowAlgorithms adds the algorithms accepted with the key to the options.
+//
+// If an algorithm is not of the family of the key, return an error.
+func allowAlgorithms(opts []gincup.JWTOption, key any, algs []string) ([]gincup.JWTOption, error) {
+	if len(algs) == 0 {
+		return opts, nil
+	}
+	for _, alg := range algs {
+		if err := checkAlgorithm(key, alg); err != nil {
+			return nil, err
+		}
+	}
+	return append(opts, gincup.WithAllowedAlgorithms(algs...)), nil
+}
+
+// checkAlgorithm checks that the algorithm is of the family of the key,
+// since gincup rejects the tokens signed with any other algorithm.
+func checkAlgorithm(key any, alg string) error {
+	var ok bool
+	switch method := jwt.GetSigningMethod(alg).(type) {
+	case *jwt.SigningMethodHMAC:
+		_, ok = key.(string)
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok = key.(*rsa.PublicKey)
+	case *jwt.SigningMethodECDSA:
+		// each curve has a single algorithm
+		if _, isECDSA := key.(*ecdsa.PublicKey); isECDSA {
+			jwk, err := gincup.NewJSONWebKey("", key)
+			ok = err == nil && jwk.Alg == method.Alg()
+		}
+	case *jwt.SigningMethodEd25519:
+		_, ok = key.(ed25519.PublicKey)
+	}
+	if !ok {
+		return fmt.Errorf("algorithm %q does not match the key", alg)
+	}
+	return nil
+}
+
+// newJWKSVerifier creates a JWT instance that verifies tokens with the keys
+// of a JSON Web Key Set file.
+//
+// Tokens must be signed with the "alg" of their key, or with the algorithm
+// of the key type when the key has no "alg".
+func newJWKSVerifier(file string, opts []gincup.JWTOption) (*gincup.JWT, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var set gincup.JSONWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	var kids []string
+	var keys []crypto.PublicKey
+	var algs []string
+	declared := false
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err == nil {
+			err = checkPublicKey(key)
+		}
+		if err == nil && jwk.Alg != "" {
+			err = checkAlgorithm(key, jwk.Alg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+		}
+
+		alg := jwk.Alg
+		if alg != "" {
+			declared = true
+		} else {
+			defaults, _ := gincup.NewJSONWebKey("", key)
+			alg = defaults.Alg
+		}
+		if !slices.Contains(algs, alg) {
+			algs = append(algs, alg)
+		}
+		kids = append(kids, jwk.Kid)
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing key in the JWKS")
+	}
+	if declared {
+		opts = append(opts, gincup.WithAllowedAlgorithms(algs...))
+	}
+
+	// the first key also verifies tokens without a key id
+	j := gincup.NewJWTVerifier(keys[0], opts...)
+	for i, kid := range kids {
+		if kid == "" {
+			continue
+		}
+		if err := j.AddVerificationKey(kid, keys[i]); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// readPEM reads the first PEM block of a file.
+func readPEM(file string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errInvalidPEM
+	}
+	return block, nil
+}
+
+// readPrivateKey reads a PKCS #8, PKCS #1 or SEC 1 private key from a PEM file.
+//
+// If the key cannot be used to sign tokens, return gincup.ErrJWTUnsupportedKey.
+func readPrivateKey(file string) (crypto.PrivateKey, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, gincup.ErrJWTUnsupportedKey
+	}
+	if err := checkPublicKey(signer.Public()); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// parsePrivateKey parses a PKCS #8, PKCS #1 or SEC 1 private key.
+func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, gincup.ErrJWTUnsupportedKey
+}
+
+// readPublicKey reads a PKIX or PKCS #1 public key, or the key of a
+// certificate, from a PEM file.
+//
+// If the key cannot be used to verify tokens, return gincup.ErrJWTUnsupportedKey.
+func readPublicKey(file string) (crypto.PublicKey, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parsePublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPublicKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// parsePublicKey parses a PKIX or PKCS #1 public key, or the key of a certificate.
+func parsePublicKey(der []byte) (crypto.PublicKey, error) {
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return key, nil
+	}
+	if cert, err := x509.ParseCertificate(der); err == nil {
+		return cert.PublicKey, nil
+	}
+	return nil, gincup.ErrJWTUnsupportedKey
+}
+
+// checkPublicKey checks that gincup supports the key, since its constructors
+// panic on unsupported keys such as P-224 ECDSA or X25519 keys.
+func checkPublicKey(key crypto.PublicKey) error {
+	if _, err := gincup.NewJSONWebKey("", key); err != nil {
+		return gincup.ErrJWTUnsupportedKey
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/okppop/gincup"
+	"github.com/stretchr/testify/assert"
+)
+
+// runCommand runs the command and returns the exit code, stdout and stderr.
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// writeFile writes a file in a temporary directory and returns its path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestMintAndVerify(t *testing.T) {
+	t.Run("secret", func(t *testing.T) {
+		code, token, _ := runCommand("", "mint", "-secret", "secret", "-sub", "test123", "-claims", `{"roles":["admin"]}`, "-iss", "cli")
+		assert.Equal(t, 0, code)
+
+		code, out, _ := runCommand("", "verify", "-secret", "secret", "-iss", "cli", strings.TrimSpace(token))
+		assert.Equal(t, 0, code)
+		var claims map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(out), &claims))
+		assert.Equal(t, "test123", claims["sub"])
+		assert.Equal(t, []any{"admin"}, claims["roles"])
+
+		// the token can be read from stdin
+		code, _, _ = runCommand(token, "verify", "-secret", "secret")
+		assert.Equal(t, 0, code)
+
+		code, _, stderr := runCommand(token, "verify", "-secret", "other")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "invalid token")
+
+		code, _, _ = runCommand(token, "verify", "-secret", "secret", "-iss", "other")
+		assert.Equal(t, 1, code)
+	})
+
+	t.Run("PEM key and JWKS", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+
+		privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+		assert.NoError(t, err)
+		privateFile := writeFile(t, "private.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+		publicDER, err := x509.MarshalPKIXPublicKey(key.Public())
+		assert.NoError(t, err)
+		publicFile := writeFile(t, "public.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+
+		jwk, err := gincup.NewJSONWebKey("k1", key.Public())
+		assert.NoError(t, err)
+		jwks, err := json.Marshal(gincup.JSONWebKeySet{Keys: []gincup.JSONWebKey{jwk}})
+		assert.NoError(t, err)
+		jwksFile := writeFile(t, "jwks.json", jwks)
+
+		code, token, _ := runCommand("", "mint", "-key", privateFile, "-kid", "k1", "-sub", "test123")
+		assert.Equal(t, 0, code)
+
+		code, _, _ = runCommand(token, "verify", "-key", publicFile)
+		assert.Equal(t, 0, code)
+		code, _, _ = runCommand(token, "verify", "-jwks", jwksFile)
+		assert.Equal(t, 0, code)
+
+		code, _, _ = runCommand(token, "verify", "-secret", "secret")
+		assert.Equal(t, 1, code)
+	})
+
+	t.Run("algorithm of the key", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+
+		publicDER, err := x509.MarshalPKIXPublicKey(key.Public())
+		assert.NoError(t, err)
+		publicFile := writeFile(t, "public.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+
+		jwks := func(alg string) string {
+			jwk, err := gincup.NewJSONWebKey("k1", key.Public())
+			assert.NoError(t, err)
+			jwk.Alg = alg
+			data, err := json.Marshal(gincup.JSONWebKeySet{Keys: []gincup.JSONWebKey{jwk}})
+			assert.NoError(t, err)
+			return writeFile(t, "jwks.json", data)
+		}
+
+		unsigned := jwt.NewWithClaims(jwt.SigningMethodPS256, jwt.MapClaims{"sub": "test123", "exp": time.Now().Add(time.Hour).Unix()})
+		unsigned.Header["kid"] = "k1"
+		token, err := unsigned.SignedString(key)
+		assert.NoError(t, err)
+
+		code, _, _ := runCommand(token, "verify", "-jwks", jwks("PS256"))
+		assert.Equal(t, 0, code)
+		code, _, _ = runCommand(token, "verify", "-jwks", jwks("RS256"))
+		assert.Equal(t, 1, code)
+		code, _, stderr := runCommand(token, "verify", "-jwks", jwks("ES256"))
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, `algorithm "ES256" does not match the key`)
+
+		code, _, _ = runCommand(token, "verify", "-key", publicFile)
+		assert.Equal(t, 1, code)
+		code, _, _ = runCommand(token, "verify", "-key", publicFile, "-alg", "RS256,PS256")
+		assert.Equal(t, 0, code)
+		code, _, stderr = runCommand(token, "verify", "-key", publicFile, "-alg", "HS256")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, `algorithm "HS256" does not match the key`)
+
+		code, _, _ = runCommand(token, "verify", "-jwks", jwks("PS256"), "-alg", "PS256")
+		assert.Equal(t, 1, code)
+	})
+
+	t.Run("unsupported keys", func(t *testing.T) {
+		p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+		assert.NoError(t, err)
+		x25519, err := ecdh.X25519().GenerateKey(rand.Reader)
+		assert.NoError(t, err)
+
+		for name, key := range map[string]crypto.PrivateKey{"P-224": p224, "X25519": x25519} {
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			assert.NoError(t, err)
+			file := writeFile(t, "private.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+			code, _, stderr := runCommand("", "mint", "-key", file, "-sub", "test123")
+			assert.Equal(t, 1, code, name)
+			assert.Contains(t, stderr, gincup.ErrJWTUnsupportedKey.Error(), name)
+		}
+
+		for name, key := range map[string]crypto.PublicKey{"P-224": p224.Public(), "X25519": x25519.PublicKey()} {
+			der, err := x509.MarshalPKIXPublicKey(key)
+			assert.NoError(t, err)
+			file := writeFile(t, "public.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+			code, _, stderr := runCommand("token", "verify", "-key", file)
+			assert.Equal(t, 1, code, name)
+			assert.Contains(t, stderr, gincup.ErrJWTUnsupportedKey.Error(), name)
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		t.Setenv("GINCUP_SECRET", "")
+
+		code, _, _ := runCommand("", "mint", "-sub", "test123")
+		assert.Equal(t, 1, code)
+		code, _, _ = runCommand("token", "verify")
+		assert.Equal(t, 1, code)
+	})
+}
+
+func TestDecode(t *testing.T) {
+	token, err := gincup.NewJWT("secret", time.Hour).GenerateTokenAndSetSubject("test123")
+	assert.NoError(t, err)
+
+	code, out, _ := runCommand("", "decode", token)
+	assert.Equal(t, 0, code)
+
+	var decoded struct {
+		Header map[string]any `json:"header"`
+		Claims map[string]any `json:"claims"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(out), &decoded))
+	assert.Equal(t, "HS256", decoded.Header["alg"])
+	assert.Equal(t, "test123", decoded.Claims["sub"])
+
+	code, _, _ = runCommand("", "decode", "invalid")
+	assert.Equal(t, 1, code)
+}
+
+func TestPasswords(t *testing.T) {
+	code, hashed, _ := runCommand("s3cret\n", "hash")
+	assert.Equal(t, 0, code)
+	hashed = strings.TrimSpace(hashed)
+
+	code, out, _ := runCommand("s3cret\n", "verify-password", "-hash", hashed)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "ok\n", out)
+
+	code, _, _ = runCommand("wrong\n", "verify-password", "-hash", hashed)
+	assert.Equal(t, 1, code)
+
+	code, _, _ = runCommand("", "hash")
+	assert.Equal(t, 1, code)
+}
+
+func TestUsage(t *testing.T) {
+	code, _, stderr := runCommand("")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "usage: gincup")
+
+	code, _, _ = runCommand("", "unknown")
+	assert.Equal(t, 2, code)
+
+	code, _, _ = runCommand("", "mint", "-unknown")
+	assert.Equal(t, 2, code)
+
+	code, _, _ = runCommand("", "decode", "a", "b")
+	assert.Equal(t, 2, code)
+}
@@ -0,0 +1,340 @@
+package gincup
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrDPoPInvalidProof  = errors.New("invalid DPoP proof")
+	ErrDPoPProofReplayed = fmt.Errorf("%w: proof already used", ErrDPoPInvalidProof)
+	ErrDPoPKeyMismatch   = errors.New("token is not bound to the DPoP key")
+	ErrDPoPBoundToken    = errors.New("token is bound to a DPoP key")
+	ErrDPoPJKTRequired   = errors.New("DPoP key thumbprint is required")
+)
+
+// dpopAlgorithms are the algorithms accepted for DPoP proofs.
+var dpopAlgorithms = []string{"ES256", "ES384", "ES512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "EdDSA"}
+
+// DPoPReplayCache remembers the ids ("jti" claims) of used DPoP proofs.
+type DPoPReplayCache interface {
+	// Use records the id of a proof and reports whether it was unused.
+	// The proof is rejected after expiresAt anyway, so the cache may
+	// forget it afterwards.
+	Use(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+}
+
+// MemoryDPoPReplayCache is a DPoPReplayCache that keeps proof ids in memory.
+//
+// Each entry is removed once its proof has expired.
+type MemoryDPoPReplayCache struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// NewMemoryDPoPReplayCache creates a new MemoryDPoPReplayCache.
+func NewMemoryDPoPReplayCache() *MemoryDPoPReplayCache {
+	return &MemoryDPoPReplayCache{
+		used: map[string]time.Time{},
+	}
+}
+
+// Use implements the DPoPReplayCache interface.
+func (s *MemoryDPoPReplayCache) Use(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.used {
+		if now.After(exp) {
+			delete(s.used, id)
+		}
+	}
+
+	if _, ok := s.used[jti]; ok {
+		return false, nil
+	}
+	s.used[jti] = expiresAt
+	return true, nil
+}
+
+// DPoPConfig configures DPoPMiddleware.
+type DPoPConfig struct {
+	// ReplayCache remembers the used proofs. It is required.
+	ReplayCache DPoPReplayCache
+
+	// ProofLifetime is how long a proof is accepted after its "iat" claim.
+	// The default is 1 minute.
+	ProofLifetime time.Duration
+
+	// RequestURL returns the URL the client sent the request to, which
+	// differs from the URL seen by the server behind a reverse proxy.
+	// The default is built from the request.
+	RequestURL func(c *gin.Context) string
+}
+
+// JWKThumbprint returns the RFC 7638 SHA-256 thumbprint of a public key,
+// base64url encoded.
+//
+// If the key is not supported, return ErrJWTUnsupportedKey.
+func JWKThumbprint(key crypto.PublicKey) (string, error) {
+	jwk, err := NewJSONWebKey("", key)
+	if err != nil {
+		return "", err
+	}
+	return jwk.thumbprint()
+}
+
+// thumbprint returns the RFC 7638 SHA-256 thumbprint of the key.
+func (k JSONWebKey) thumbprint() (string, error) {
+	// the required members only, in lexicographic order without whitespace;
+	// encoding/json sorts the keys of maps
+	var members map[string]string
+	switch k.Kty {
+	case "RSA":
+		members = map[string]string{"e": k.E, "kty": k.Kty, "n": k.N}
+	case "EC":
+		members = map[string]string{"crv": k.Crv, "kty": k.Kty, "x": k.X, "y": k.Y}
+	case "OKP":
+		members = map[string]string{"crv": k.Crv, "kty": k.Kty, "x": k.X}
+	default:
+		return "", ErrJWTUnsupportedKey
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// GenerateDPoPToken generates a JWT token bound to the DPoP key with the
+// thumbprint jkt, as returned by JWKThumbprint.
+//
+// If jkt is empty, return ErrDPoPJKTRequired.
+func (j *JWT) GenerateDPoPToken(jkt string) (string, error) {
+	if jkt == "" {
+		return "", ErrDPoPJKTRequired
+	}
+
+	return j.signClaims(jwt.MapClaims{
+		"cnf": map[string]string{"jkt": jkt},
+	})
+}
+
+// GenerateDPoPTokenAndSetSubject generates a JWT token with the subject,
+// bound to the DPoP key with the thumbprint jkt.
+//
+// If jkt is empty, return ErrDPoPJKTRequired.
+func (j *JWT) GenerateDPoPTokenAndSetSubject(sub, jkt string) (string, error) {
+	if jkt == "" {
+		return "", ErrDPoPJKTRequired
+	}
+
+	return j.signClaims(jwt.MapClaims{
+		"sub": sub,
+		"cnf": map[string]string{"jkt": jkt},
+	})
+}
+
+// DPoPMiddleware is a middleware that validates a DPoP-bound token (RFC 9449)
+// and sets the subject and the claims to the context as with MiddlewareWithSubject.
+//
+// The token is read from the "Authorization: DPoP" header, and the request
+// must carry a single DPoP proof signed by the key the token is bound to,
+// for the method and the URL of the request.
+//
+// If the token or the proof is missing, invalid or expired, or the proof
+// has already been used, the middleware will return a 401 Unauthorized status.
+//
+// If the replay cache is nil, panic.
+func (j *JWT) DPoPMiddleware(config DPoPConfig) gin.HandlerFunc {
+	if config.ReplayCache == nil {
+		panic("replay cache is required")
+	}
+	if config.ProofLifetime <= 0 {
+		config.ProofLifetime = 1 * time.Minute
+	}
+	if config.RequestURL == nil {
+		config.RequestURL = requestURL
+	}
+	extractor := HeaderExtractor("Authorization", "DPoP")
+
+	return func(c *gin.Context) {
+		token, err := extractor.ExtractToken(c)
+		if err != nil {
+			j.abortDPoP(c, err)
+			return
+		}
+
+		proofs := c.Request.Header.Values("DPoP")
+		if len(proofs) != 1 {
+			j.abortDPoP(c, ErrDPoPInvalidProof)
+			return
+		}
+
+		jkt, jti, iat, err := j.verifyDPoPProof(c, config, proofs[0], token)
+		if err != nil {
+			j.abortDPoP(c, err)
+			return
+		}
+
+		claims, err := j.parseToken(c.Request.Context(), token)
+		if err != nil {
+			j.abortDPoP(c, err)
+			return
+		}
+		bound := dpopThumbprint(claims)
+		if bound == "" || subtle.ConstantTimeCompare([]byte(bound), []byte(jkt)) != 1 {
+			j.abortDPoP(c, ErrDPoPKeyMismatch)
+			return
+		}
+
+		// record the proof last, so that a rejected request does not burn it
+		unused, err := config.ReplayCache.Use(c.Request.Context(), jti, iat.Add(config.ProofLifetime+j.leeway))
+		if err != nil {
+			j.respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !unused {
+			j.abortDPoP(c, ErrDPoPProofReplayed)
+			return
+		}
+
+		if err := setClaims(c, claims); err != nil {
+			j.abortDPoP(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// verifyDPoPProof verifies a DPoP proof for the request and the access token,
+// and returns the thumbprint of its key, its id and its issued at time.
+func (j *JWT) verifyDPoPProof(c *gin.Context, config DPoPConfig, proof, token string) (string, string, time.Time, error) {
+	var jwk JSONWebKey
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
+		if typ, _ := t.Header["typ"].(string); typ != "dpop+jwt" {
+			return nil, ErrDPoPInvalidProof
+		}
+
+		header, ok := t.Header["jwk"].(map[string]interface{})
+		if !ok {
+			return nil, ErrDPoPInvalidProof
+		}
+		// the key must be public
+		if _, ok := header["d"]; ok {
+			return nil, ErrDPoPInvalidProof
+		}
+		data, err := json.Marshal(header)
+		if err != nil {
+			return nil, ErrDPoPInvalidProof
+		}
+		if err := json.Unmarshal(data, &jwk); err != nil {
+			return nil, ErrDPoPInvalidProof
+		}
+
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, ErrDPoPInvalidProof
+		}
+		if !slices.Contains(compatibleAlgorithms(key), t.Method.Alg()) {
+			return nil, ErrDPoPInvalidProof
+		}
+		return key, nil
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(proof, claims, keyFunc,
+		jwt.WithValidMethods(dpopAlgorithms),
+		jwt.WithJSONNumber(),
+		jwt.WithLeeway(j.leeway),
+		jwt.WithTimeFunc(j.now),
+	)
+	if err != nil {
+		return "", "", time.Time{}, ErrDPoPInvalidProof
+	}
+
+	jti, _ := claims["jti"].(string)
+	htm, _ := claims["htm"].(string)
+	htu, _ := claims["htu"].(string)
+	ath, _ := claims["ath"].(string)
+	iat, ok := numericDateClaim(claims, "iat")
+	if jti == "" || !ok {
+		return "", "", time.Time{}, ErrDPoPInvalidProof
+	}
+
+	if htm != c.Request.Method || !sameURL(htu, config.RequestURL(c)) {
+		return "", "", time.Time{}, ErrDPoPInvalidProof
+	}
+
+	now := j.now()
+	if iat.After(now.Add(j.leeway)) || now.After(iat.Add(config.ProofLifetime+j.leeway)) {
+		return "", "", time.Time{}, ErrDPoPInvalidProof
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	if subtle.ConstantTimeCompare([]byte(ath), []byte(base64.RawURLEncoding.EncodeToString(sum[:]))) != 1 {
+		return "", "", time.Time{}, ErrDPoPInvalidProof
+	}
+
+	jkt, err := jwk.thumbprint()
+	if err != nil {
+		return "", "", time.Time{}, ErrDPoPInvalidProof
+	}
+	return jkt, jti, iat, nil
+}
+
+// abortDPoP aborts the request with a 401 Unauthorized status and a DPoP challenge.
+func (j *JWT) abortDPoP(c *gin.Context, err error) {
+	j.setSchemeChallenge(c, "DPoP", http.StatusUnauthorized, err)
+	j.respondError(c, http.StatusUnauthorized, err)
+}
+
+// dpopThumbprint returns the "cnf.jkt" claim of a token bound to a DPoP key.
+func dpopThumbprint(claims jwt.MapClaims) string {
+	cnf, ok := claims["cnf"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	jkt, _ := cnf["jkt"].(string)
+	return jkt
+}
+
+// requestURL returns the URL of the request without the query and the fragment.
+func requestURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + c.Request.URL.Path
+}
+
+// sameURL reports whether two URLs are the same, ignoring the query and the fragment.
+func sameURL(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Scheme == ub.Scheme && strings.EqualFold(ua.Host, ub.Host) && ua.EscapedPath() == ub.EscapedPath()
+}
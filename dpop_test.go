@@ -0,0 +1,208 @@
+package gincup
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// newDPoPProof creates a DPoP proof signed by the key. The claims override
+// the claims of a valid proof for the request and the token.
+func newDPoPProof(t *testing.T, key *ecdsa.PrivateKey, method, htu, token string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	jwk, err := NewJSONWebKey("", key.Public())
+	assert.NoError(t, err)
+	data, err := json.Marshal(jwk)
+	assert.NoError(t, err)
+	var header map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &header))
+
+	sum := sha256.Sum256([]byte(token))
+	proofClaims := jwt.MapClaims{
+		"jti": rand.Text(),
+		"htm": method,
+		"htu": htu,
+		"iat": time.Now().Unix(),
+		"ath": base64.RawURLEncoding.EncodeToString(sum[:]),
+	}
+	for name, value := range claims {
+		proofClaims[name] = value
+	}
+
+	proof := jwt.NewWithClaims(jwt.SigningMethodES256, proofClaims)
+	proof.Header["typ"] = "dpop+jwt"
+	proof.Header["jwk"] = header
+	signed, err := proof.SignedString(key)
+	assert.NoError(t, err)
+	return signed
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	// RFC 7638 section 3.1
+	jwk := JSONWebKey{
+		Kty: "RSA",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+	}
+	pub, err := jwk.PublicKey()
+	assert.NoError(t, err)
+
+	jkt, err := JWKThumbprint(pub)
+	assert.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", jkt)
+
+	_, err = JWKThumbprint([]byte("secret"))
+	assert.ErrorIs(t, err, ErrJWTUnsupportedKey)
+}
+
+func TestDPoPMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	j := NewJWT("secret", 1*time.Hour)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	jkt, err := JWKThumbprint(key.Public())
+	assert.NoError(t, err)
+
+	router := gin.New()
+	router.GET("/test", j.DPoPMiddleware(DPoPConfig{ReplayCache: NewMemoryDPoPReplayCache()}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"subject": j.GetSubjectFromGinContext(c)})
+	})
+	router.GET("/bearer", j.MiddlewareWithSubject(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	const htu = "http://example.com/test"
+	request := func(token, proof string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", htu+"?page=1", nil)
+		req.Header.Set("Authorization", "DPoP "+token)
+		if proof != "" {
+			req.Header.Set("DPoP", proof)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	token, err := j.GenerateDPoPTokenAndSetSubject("test123", jkt)
+	assert.NoError(t, err)
+
+	t.Run("valid proof", func(t *testing.T) {
+		w := request(token, newDPoPProof(t, key, "GET", htu, token, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subject":"test123"}`, w.Body.String())
+	})
+
+	t.Run("replayed proof", func(t *testing.T) {
+		proof := newDPoPProof(t, key, "GET", htu, token, nil)
+		assert.Equal(t, http.StatusOK, request(token, proof).Code)
+
+		w := request(token, proof)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `DPoP error="invalid_dpop_proof"`)
+	})
+
+	t.Run("missing proof", func(t *testing.T) {
+		w := request(token, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("invalid proof claims", func(t *testing.T) {
+		tests := map[string]jwt.MapClaims{
+			"method":     {"htm": "POST"},
+			"url":        {"htu": "http://example.com/other"},
+			"old iat":    {"iat": time.Now().Add(-5 * time.Minute).Unix()},
+			"future iat": {"iat": time.Now().Add(5 * time.Minute).Unix()},
+			"token hash": {"ath": "wrong"},
+			"no jti":     {"jti": ""},
+		}
+		for name, claims := range tests {
+			t.Run(name, func(t *testing.T) {
+				w := request(token, newDPoPProof(t, key, "GET", htu, token, claims))
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_dpop_proof"`)
+			})
+		}
+	})
+
+	t.Run("proof signed by another key", func(t *testing.T) {
+		other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+
+		w := request(token, newDPoPProof(t, other, "GET", htu, token, nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+	})
+
+	t.Run("unbound token", func(t *testing.T) {
+		bearer, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		w := request(bearer, newDPoPProof(t, key, "GET", htu, bearer, nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("bound token used as bearer", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/bearer", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"token is bound to a DPoP key"}`, w.Body.String())
+	})
+
+	t.Run("empty thumbprint", func(t *testing.T) {
+		_, err := j.GenerateDPoPToken("")
+		assert.ErrorIs(t, err, ErrDPoPJKTRequired)
+		_, err = j.GenerateDPoPTokenAndSetSubject("test123", "")
+		assert.ErrorIs(t, err, ErrDPoPJKTRequired)
+
+		// a malformed binding is not a bearer token either
+		token, err := GenerateTokenWithClaims(j, map[string]any{"sub": "test123", "cnf": map[string]string{"jkt": ""}})
+		assert.NoError(t, err)
+		req := httptest.NewRequest("GET", "/bearer", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("nil replay cache panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			j.DPoPMiddleware(DPoPConfig{})
+		})
+	})
+}
+
+func TestMemoryDPoPReplayCache(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryDPoPReplayCache()
+
+	unused, err := cache.Use(ctx, "a", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, unused)
+
+	unused, err = cache.Use(ctx, "a", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, unused)
+
+	// expired entries are forgotten
+	_, err = cache.Use(ctx, "b", time.Now().Add(-time.Second))
+	assert.NoError(t, err)
+	unused, err = cache.Use(ctx, "b", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, unused)
+}
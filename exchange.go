@@ -0,0 +1,278 @@
+package gincup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// TokenExchangeGrantType is the grant type of RFC 8693 token exchange requests.
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// AccessTokenType is the token type identifier of access tokens.
+	AccessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+
+	// JWTTokenType is the token type identifier of JWTs.
+	JWTTokenType = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+var (
+	ErrTokenExchangeInvalidRequest = errors.New("invalid token exchange request")
+	ErrTokenExchangeInvalidScope   = errors.New("invalid scope")
+	ErrTokenExchangeDenied         = errors.New("token exchange denied")
+)
+
+// TokenExchangeRequest is a validated token exchange request.
+type TokenExchangeRequest struct {
+	// Subject are the claims of the subject token, the party the new token
+	// is issued for.
+	Subject jwt.MapClaims
+
+	// Actor are the claims of the actor token, the party that will act on
+	// behalf of the subject.
+	Actor jwt.MapClaims
+
+	// Scopes are the requested scopes. They are a subset of the scopes of
+	// the subject token.
+	Scopes []string
+
+	// Audience are the requested audiences.
+	Audience []string
+}
+
+// TokenExchangePolicy decides whether the actor may act on behalf of the subject.
+//
+// Returning an error denies the exchange.
+type TokenExchangePolicy func(ctx context.Context, req *TokenExchangeRequest) error
+
+// TokenExchanger exchanges tokens for delegated tokens (RFC 8693).
+//
+// A delegated token has the subject of the subject token and an "act" claim
+// with the subject of the actor token. When the subject token is itself
+// delegated, its "act" claim is nested in the new one, so the whole chain
+// of actors is kept.
+type TokenExchanger struct {
+	jwt    *JWT
+	policy TokenExchangePolicy
+}
+
+// TokenExchangeResponse is the response of a token exchange.
+type TokenExchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Scope           string `json:"scope,omitempty"`
+}
+
+// NewTokenExchanger creates a new TokenExchanger that validates and signs
+// tokens with the JWT instance.
+//
+// If the JWT instance or the policy is nil, panic.
+func NewTokenExchanger(j *JWT, policy TokenExchangePolicy) *TokenExchanger {
+	if j == nil {
+		panic("JWT is required")
+	}
+	if policy == nil {
+		panic("policy is required")
+	}
+
+	return &TokenExchanger{
+		jwt:    j,
+		policy: policy,
+	}
+}
+
+// Exchange validates the subject and actor tokens and issues a token for the
+// subject, delegated to the actor.
+//
+// If no scope is requested, the new token keeps the scopes of the subject token.
+//
+// The new token keeps the "auth_time" claim of the subject token, and expires
+// no later than the subject and actor tokens.
+//
+// If a token is missing, invalid or expired, return an error.
+// If a token is bound to a DPoP key, return ErrDPoPBoundToken.
+// If a requested scope is not granted to the subject token, return ErrTokenExchangeInvalidScope.
+// If the policy denies the exchange, return an error wrapping ErrTokenExchangeDenied.
+func (e *TokenExchanger) Exchange(ctx context.Context, subjectToken, actorToken string, scopes, audience []string) (TokenExchangeResponse, error) {
+	if subjectToken == "" || actorToken == "" {
+		return TokenExchangeResponse{}, ErrTokenExchangeInvalidRequest
+	}
+
+	// tokens bound to a key would lose their binding in the new token
+	subject, err := e.jwt.parseBearerToken(ctx, subjectToken)
+	if err != nil {
+		return TokenExchangeResponse{}, err
+	}
+	actor, err := e.jwt.parseBearerToken(ctx, actorToken)
+	if err != nil {
+		return TokenExchangeResponse{}, err
+	}
+
+	sub, _ := subject.GetSubject()
+	actorSub, _ := actor.GetSubject()
+	if sub == "" || actorSub == "" {
+		return TokenExchangeResponse{}, ErrJWTInvalidClaims
+	}
+
+	granted := scopesOf(subject)
+	if len(scopes) == 0 {
+		scopes = granted
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return TokenExchangeResponse{}, ErrTokenExchangeInvalidScope
+		}
+	}
+
+	req := &TokenExchangeRequest{
+		Subject:  subject,
+		Actor:    actor,
+		Scopes:   scopes,
+		Audience: audience,
+	}
+	if err := e.policy(ctx, req); err != nil {
+		return TokenExchangeResponse{}, errors.Join(ErrTokenExchangeDenied, err)
+	}
+
+	act := map[string]interface{}{"sub": actorSub}
+	if prior, ok := subject["act"].(map[string]interface{}); ok {
+		act["act"] = prior
+	}
+	claims := jwt.MapClaims{
+		"sub": sub,
+		"act": act,
+	}
+	if len(scopes) > 0 {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+	if len(audience) > 0 {
+		claims["aud"] = jwt.ClaimStrings(audience)
+	}
+
+	// keep the login time of the subject, a delegated token is not a new login
+	if authTime, ok := numericDateClaim(subject, "auth_time"); ok {
+		claims["auth_time"] = jwt.NewNumericDate(authTime)
+	} else if issuedAt, ok := numericDateClaim(subject, "iat"); ok && e.jwt.session != nil {
+		claims["auth_time"] = jwt.NewNumericDate(issuedAt)
+	}
+
+	// a delegated token must not outlive the tokens it was exchanged for,
+	// or chained exchanges would extend the delegation forever
+	now := e.jwt.now()
+	expiresAt := now.Add(e.jwt.expireDuration)
+	for _, input := range []jwt.MapClaims{subject, actor} {
+		if exp, err := input.GetExpirationTime(); err == nil && exp != nil && exp.Before(expiresAt) {
+			expiresAt = exp.Time
+		}
+	}
+	claims["exp"] = jwt.NewNumericDate(expiresAt)
+
+	token, err := e.jwt.signClaims(claims)
+	if err != nil {
+		return TokenExchangeResponse{}, err
+	}
+
+	return TokenExchangeResponse{
+		AccessToken:     token,
+		IssuedTokenType: AccessTokenType,
+		TokenType:       "Bearer",
+		ExpiresIn:       expiresAt.Unix() - now.Unix(),
+		Scope:           strings.Join(scopes, " "),
+	}, nil
+}
+
+// Handler is a handler of RFC 8693 token exchange requests.
+//
+// The request is a form with the "grant_type", "subject_token",
+// "subject_token_type", "actor_token" and "actor_token_type" fields, and the
+// optional "scope" and "audience" fields.
+//
+// If the request is malformed or a token is invalid, the handler will return a 400 Bad Request status.
+// If the policy denies the exchange, the handler will return a 403 Forbidden status.
+func (e *TokenExchanger) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.PostForm("grant_type") != TokenExchangeGrantType ||
+			!isExchangeableTokenType(c.PostForm("subject_token_type")) ||
+			!isExchangeableTokenType(c.PostForm("actor_token_type")) {
+			e.jwt.respondError(c, http.StatusBadRequest, ErrTokenExchangeInvalidRequest)
+			return
+		}
+		if requested := c.PostForm("requested_token_type"); requested != "" && requested != AccessTokenType {
+			e.jwt.respondError(c, http.StatusBadRequest, ErrTokenExchangeInvalidRequest)
+			return
+		}
+
+		resp, err := e.Exchange(c.Request.Context(),
+			c.PostForm("subject_token"),
+			c.PostForm("actor_token"),
+			strings.Fields(c.PostForm("scope")),
+			c.PostFormArray("audience"),
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrTokenExchangeDenied):
+				e.jwt.respondError(c, http.StatusForbidden, err)
+			case errors.Is(err, ErrTokenExchangeInvalidRequest),
+				errors.Is(err, ErrTokenExchangeInvalidScope),
+				errors.Is(err, ErrJWTInvalidToken),
+				errors.Is(err, ErrJWTTokenExpired),
+				errors.Is(err, ErrJWTTokenRevoked),
+				errors.Is(err, ErrJWTSessionExpired),
+				errors.Is(err, ErrJWTInvalidClaims),
+				errors.Is(err, ErrDPoPBoundToken):
+				e.jwt.respondError(c, http.StatusBadRequest, err)
+			default:
+				e.jwt.respondError(c, http.StatusInternalServerError, err)
+			}
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetActorFromGinContext gets the subject of the current actor, the party
+// acting on behalf of the subject of a delegated token.
+//
+// If the token is not delegated, the function will return an empty string.
+func (j *JWT) GetActorFromGinContext(c *gin.Context) string {
+	chain := j.GetActorChainFromGinContext(c)
+	if len(chain) == 0 {
+		return ""
+	}
+	return chain[0]
+}
+
+// GetActorChainFromGinContext gets the subjects of the actors of a delegated
+// token, from the current actor to the first one.
+//
+// If the token is not delegated, the function will return nil.
+func (j *JWT) GetActorChainFromGinContext(c *gin.Context) []string {
+	claims, ok := claimsFromGinContext(c)
+	if !ok {
+		return nil
+	}
+
+	var chain []string
+	act, _ := claims["act"].(map[string]interface{})
+	for act != nil {
+		sub, _ := act["sub"].(string)
+		chain = append(chain, sub)
+		act, _ = act["act"].(map[string]interface{})
+	}
+	return chain
+}
+
+// isExchangeableTokenType reports whether tokens of the type can be exchanged.
+func isExchangeableTokenType(tokenType string) bool {
+	return tokenType == AccessTokenType || tokenType == JWTTokenType
+}
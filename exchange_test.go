@@ -0,0 +1,269 @@
+package gincup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenExchanger(t *testing.T) {
+	ctx := context.Background()
+	j := NewJWT("secret", 1*time.Hour)
+
+	allowSupport := func(ctx context.Context, req *TokenExchangeRequest) error {
+		if !HasRole("support")(req.Actor) {
+			return errors.New("actor is not support staff")
+		}
+		return nil
+	}
+	exchanger := NewTokenExchanger(j, allowSupport)
+
+	customer, err := GenerateTokenWithClaims(j, map[string]any{"sub": "customer", "scope": "read write"})
+	assert.NoError(t, err)
+	support, err := GenerateTokenWithClaims(j, map[string]any{"sub": "alice", "roles": []string{"support"}})
+	assert.NoError(t, err)
+
+	t.Run("delegated token", func(t *testing.T) {
+		resp, err := exchanger.Exchange(ctx, customer, support, []string{"read"}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, AccessTokenType, resp.IssuedTokenType)
+		assert.Equal(t, "read", resp.Scope)
+
+		claims, err := j.parseToken(ctx, resp.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "customer", claims["sub"])
+		assert.Equal(t, map[string]interface{}{"sub": "alice"}, claims["act"])
+		assert.Equal(t, "read", claims["scope"])
+	})
+
+	t.Run("nested actors", func(t *testing.T) {
+		first, err := exchanger.Exchange(ctx, customer, support, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "read write", first.Scope)
+
+		service, err := GenerateTokenWithClaims(j, map[string]any{"sub": "billing", "roles": []string{"support"}})
+		assert.NoError(t, err)
+		second, err := exchanger.Exchange(ctx, first.AccessToken, service, nil, []string{"https://billing.example.com"})
+		assert.NoError(t, err)
+
+		claims, err := j.parseToken(ctx, second.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "customer", claims["sub"])
+		assert.Equal(t, map[string]interface{}{
+			"sub": "billing",
+			"act": map[string]interface{}{"sub": "alice"},
+		}, claims["act"])
+		aud, err := claims.GetAudience()
+		assert.NoError(t, err)
+		assert.Equal(t, jwt.ClaimStrings{"https://billing.example.com"}, aud)
+	})
+
+	t.Run("login time is kept", func(t *testing.T) {
+		authTime := time.Now().Add(-30 * time.Minute).Unix()
+		subject, err := GenerateTokenWithClaims(j, map[string]any{"sub": "customer", "auth_time": authTime})
+		assert.NoError(t, err)
+
+		resp, err := exchanger.Exchange(ctx, subject, support, nil, nil)
+		assert.NoError(t, err)
+		claims, err := j.parseToken(ctx, resp.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, json.Number(strconv.FormatInt(authTime, 10)), claims["auth_time"])
+
+		// no login time is invented for subjects without one
+		resp, err = exchanger.Exchange(ctx, customer, support, nil, nil)
+		assert.NoError(t, err)
+		claims, err = j.parseToken(ctx, resp.AccessToken)
+		assert.NoError(t, err)
+		assert.NotContains(t, claims, "auth_time")
+	})
+
+	t.Run("chained exchanges do not outlive the subject token", func(t *testing.T) {
+		now := time.Now()
+		j := NewJWT("secret", 1*time.Hour, WithTimeFunc(func() time.Time { return now }))
+		exchanger := NewTokenExchanger(j, allowSupport)
+
+		subject, err := j.GenerateTokenAndSetSubject("customer")
+		assert.NoError(t, err)
+		expiresAt := now.Add(time.Hour).Unix()
+
+		token := subject
+		for range 5 {
+			now = now.Add(10 * time.Minute)
+			actor, err := GenerateTokenWithClaims(j, map[string]any{"sub": "alice", "roles": []string{"support"}})
+			assert.NoError(t, err)
+
+			resp, err := exchanger.Exchange(ctx, token, actor, nil, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, expiresAt-now.Unix(), resp.ExpiresIn)
+
+			claims, err := j.parseToken(ctx, resp.AccessToken)
+			assert.NoError(t, err)
+			assert.Equal(t, json.Number(strconv.FormatInt(expiresAt, 10)), claims["exp"])
+			token = resp.AccessToken
+		}
+
+		now = now.Add(11 * time.Minute)
+		_, err = j.parseToken(ctx, token)
+		assert.ErrorIs(t, err, ErrJWTTokenExpired)
+
+		// nor the actor token
+		actor, err := GenerateTokenWithClaims(j, map[string]any{
+			"sub":   "alice",
+			"roles": []string{"support"},
+			"exp":   now.Add(5 * time.Minute).Unix(),
+		})
+		assert.NoError(t, err)
+		subject, err = j.GenerateTokenAndSetSubject("customer")
+		assert.NoError(t, err)
+		resp, err := exchanger.Exchange(ctx, subject, actor, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5*60), resp.ExpiresIn)
+	})
+
+	t.Run("DPoP-bound tokens", func(t *testing.T) {
+		bound, err := j.GenerateDPoPTokenAndSetSubject("victim", "jkt")
+		assert.NoError(t, err)
+
+		_, err = exchanger.Exchange(ctx, bound, support, nil, nil)
+		assert.ErrorIs(t, err, ErrDPoPBoundToken)
+
+		boundActor, err := GenerateTokenWithClaims(j, map[string]any{
+			"sub":   "alice",
+			"roles": []string{"support"},
+			"cnf":   map[string]string{"jkt": "jkt"},
+		})
+		assert.NoError(t, err)
+		_, err = exchanger.Exchange(ctx, customer, boundActor, nil, nil)
+		assert.ErrorIs(t, err, ErrDPoPBoundToken)
+	})
+
+	t.Run("scope not granted", func(t *testing.T) {
+		_, err := exchanger.Exchange(ctx, customer, support, []string{"admin"}, nil)
+		assert.ErrorIs(t, err, ErrTokenExchangeInvalidScope)
+	})
+
+	t.Run("denied by policy", func(t *testing.T) {
+		other, err := j.GenerateTokenAndSetSubject("mallory")
+		assert.NoError(t, err)
+
+		_, err = exchanger.Exchange(ctx, customer, other, nil, nil)
+		assert.ErrorIs(t, err, ErrTokenExchangeDenied)
+	})
+
+	t.Run("invalid tokens", func(t *testing.T) {
+		_, err := exchanger.Exchange(ctx, "invalid", support, nil, nil)
+		assert.ErrorIs(t, err, ErrJWTInvalidToken)
+
+		_, err = exchanger.Exchange(ctx, customer, "", nil, nil)
+		assert.ErrorIs(t, err, ErrTokenExchangeInvalidRequest)
+	})
+
+	t.Run("nil policy panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewTokenExchanger(j, nil)
+		})
+	})
+}
+
+func TestTokenExchangerHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	j := NewJWT("secret", 1*time.Hour)
+	exchanger := NewTokenExchanger(j, func(ctx context.Context, req *TokenExchangeRequest) error {
+		if req.Actor["sub"] != "alice" {
+			return errors.New("denied")
+		}
+		return nil
+	})
+
+	router := gin.New()
+	router.POST("/token", exchanger.Handler())
+	router.GET("/test", j.MiddlewareWithSubject(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"subject": j.GetSubjectFromGinContext(c),
+			"actor":   j.GetActorFromGinContext(c),
+			"chain":   j.GetActorChainFromGinContext(c),
+		})
+	})
+
+	customer, err := j.GenerateTokenAndSetSubject("customer")
+	assert.NoError(t, err)
+	alice, err := j.GenerateTokenAndSetSubject("alice")
+	assert.NoError(t, err)
+
+	exchange := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	form := func(actorToken string) url.Values {
+		return url.Values{
+			"grant_type":         {TokenExchangeGrantType},
+			"subject_token":      {customer},
+			"subject_token_type": {AccessTokenType},
+			"actor_token":        {actorToken},
+			"actor_token_type":   {JWTTokenType},
+		}
+	}
+
+	t.Run("exchange and use", func(t *testing.T) {
+		w := exchange(form(alice))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		var resp TokenExchangeResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.InDelta(t, 3600, resp.ExpiresIn, 1)
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+resp.AccessToken)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subject":"customer","actor":"alice","chain":["alice"]}`, w.Body.String())
+	})
+
+	t.Run("not delegated", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+customer)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.JSONEq(t, `{"subject":"customer","actor":"","chain":null}`, w.Body.String())
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		mallory, err := j.GenerateTokenAndSetSubject("mallory")
+		assert.NoError(t, err)
+
+		w := exchange(form(mallory))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"message":"token exchange denied"}`, w.Body.String())
+	})
+
+	t.Run("invalid grant type", func(t *testing.T) {
+		f := form(alice)
+		f.Set("grant_type", "password")
+		w := exchange(f)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid subject token", func(t *testing.T) {
+		f := form(alice)
+		f.Set("subject_token", "invalid")
+		w := exchange(f)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
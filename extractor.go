@@ -0,0 +1,99 @@
+package gincup
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrJWTTokenNotFound = errors.New("token not found")
+)
+
+// defaultTokenExtractors read the token from the Authorization header.
+var defaultTokenExtractors = []TokenExtractor{HeaderExtractor("Authorization", "Bearer")}
+
+// TokenExtractor extracts a token from a request.
+type TokenExtractor interface {
+	// ExtractToken returns the token of the request.
+	//
+	// If the request has no token, return an error wrapping ErrJWTTokenNotFound.
+	// If the request has a malformed token, return another error.
+	ExtractToken(c *gin.Context) (string, error)
+}
+
+// TokenExtractorFunc is a function that implements the TokenExtractor interface.
+type TokenExtractorFunc func(c *gin.Context) (string, error)
+
+// ExtractToken implements the TokenExtractor interface.
+func (f TokenExtractorFunc) ExtractToken(c *gin.Context) (string, error) {
+	return f(c)
+}
+
+// HeaderExtractor reads the token from a header in the format
+// "<scheme> <token>". The scheme is case-insensitive.
+//
+// If the scheme is empty, the whole header value is the token.
+//
+// A missing header is reported as ErrJWTTokenNotFound wrapped with
+// ErrJWTInvalidAuthorizationHeader, and a header with another scheme
+// as ErrJWTInvalidAuthorizationHeader.
+func HeaderExtractor(name, scheme string) TokenExtractor {
+	prefix := scheme + " "
+
+	return TokenExtractorFunc(func(c *gin.Context) (string, error) {
+		header := c.GetHeader(name)
+		if header == "" {
+			return "", fmt.Errorf("%w: %w", ErrJWTInvalidAuthorizationHeader, ErrJWTTokenNotFound)
+		}
+
+		if scheme == "" {
+			return header, nil
+		}
+
+		// trim scheme prefix
+		if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+			return "", ErrJWTInvalidAuthorizationHeader
+		}
+		return header[len(prefix):], nil
+	})
+}
+
+// CookieExtractor reads the token from a cookie.
+func CookieExtractor(name string) TokenExtractor {
+	return TokenExtractorFunc(func(c *gin.Context) (string, error) {
+		token, err := c.Cookie(name)
+		if err != nil || token == "" {
+			return "", ErrJWTTokenNotFound
+		}
+		return token, nil
+	})
+}
+
+// QueryExtractor reads the token from a query parameter.
+//
+// Tokens in URLs may end up in logs, so it is meant for clients that cannot
+// set headers, such as WebSocket and EventSource clients.
+func QueryExtractor(key string) TokenExtractor {
+	return TokenExtractorFunc(func(c *gin.Context) (string, error) {
+		token := c.Query(key)
+		if token == "" {
+			return "", ErrJWTTokenNotFound
+		}
+		return token, nil
+	})
+}
+
+// FormExtractor reads the token from a field of a urlencoded or multipart
+// form body.
+func FormExtractor(field string) TokenExtractor {
+	return TokenExtractorFunc(func(c *gin.Context) (string, error) {
+		token := c.PostForm(field)
+		if token == "" {
+			return "", ErrJWTTokenNotFound
+		}
+		return token, nil
+	})
+}
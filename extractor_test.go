@@ -0,0 +1,137 @@
+package gincup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenExtractors(t *testing.T) {
+	j := NewJWT("secret", 1*time.Hour, WithTokenExtractors(
+		HeaderExtractor("Authorization", "Token"),
+		CookieExtractor("access_token"),
+		QueryExtractor("access_token"),
+		FormExtractor("access_token"),
+	))
+
+	router := gin.New()
+	router.Use(j.MiddlewareWithSubject())
+	router.Any("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"subject": j.GetSubjectFromGinContext(c)})
+	})
+
+	token, err := j.GenerateTokenAndSetSubject("test123")
+	assert.NoError(t, err)
+
+	t.Run("header with custom scheme", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "token "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subject":"test123"}`, w.Body.String())
+	})
+
+	t.Run("cookie", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test?access_token="+token, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("form", func(t *testing.T) {
+		form := url.Values{"access_token": {token}}
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("first token wins", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test?access_token="+token, nil)
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: "invalid.token.here"})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"invalid token"}`, w.Body.String())
+	})
+
+	t.Run("malformed header", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test?access_token="+token, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"invalid Authorization header format"}`, w.Body.String())
+	})
+
+	t.Run("no token", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"invalid Authorization header format"}`, w.Body.String())
+	})
+
+	t.Run("cookie only", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour, WithTokenExtractors(CookieExtractor("access_token")))
+
+		router := gin.New()
+		router.Use(j.Middleware())
+		router.GET("/test", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"token not found"}`, w.Body.String())
+	})
+}
+
+func TestHeaderExtractor(t *testing.T) {
+	extract := func(extractor TokenExtractor, name, value string) (string, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		if value != "" {
+			c.Request.Header.Set(name, value)
+		}
+		return extractor.ExtractToken(c)
+	}
+
+	token, err := extract(HeaderExtractor("X-Api-Token", ""), "X-Api-Token", "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", token)
+
+	_, err = extract(HeaderExtractor("X-Api-Token", ""), "X-Api-Token", "")
+	assert.ErrorIs(t, err, ErrJWTTokenNotFound)
+
+	_, err = extract(HeaderExtractor("Authorization", "Bearer"), "Authorization", "Bearer")
+	assert.ErrorIs(t, err, ErrJWTInvalidAuthorizationHeader)
+	assert.NotErrorIs(t, err, ErrJWTTokenNotFound)
+}
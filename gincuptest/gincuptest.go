@@ -0,0 +1,254 @@
+// Package gincuptest provides helpers for testing gin routes protected by gincup.
+//
+// A test creates a JWT with NewJWT, protects the routes of its router with the
+// middlewares of the JWT, and sends requests carrying valid, expired, tampered
+// or wrongly scoped tokens:
+//
+//	j := gincuptest.NewJWT()
+//	router := gin.New()
+//	router.GET("/orders", j.MiddlewareWithSubject(), j.RequireScopes("orders:read"), handler)
+//
+//	w := gincuptest.Serve(router, j.ScopedRequest(t, "GET", "/orders", "alice", "orders:read"))
+//	gincuptest.AssertStatus(t, w, http.StatusOK)
+//
+//	w = gincuptest.Serve(router, j.ExpiredRequest(t, "GET", "/orders", "alice"))
+//	gincuptest.AssertUnauthorized(t, w)
+//
+// The JWT uses a fake clock, so expiration is tested by advancing the clock
+// instead of sleeping.
+package gincuptest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/okppop/gincup"
+)
+
+const (
+	// Secret is the HMAC secret of the JWT created by NewJWT.
+	Secret = "gincuptest-secret"
+
+	// ExpireDuration is the expire duration of the JWT created by NewJWT.
+	ExpireDuration = 1 * time.Hour
+)
+
+// Clock is a fake clock for tests. It only moves when it is told to.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock creates a new Clock set to the time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Set sets the time of the clock.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+}
+
+// JWT is a gincup.JWT signed with Secret whose time is given by Clock.
+type JWT struct {
+	*gincup.JWT
+
+	// Clock is the clock used to issue and validate tokens.
+	// It starts at the current time.
+	Clock *Clock
+}
+
+// NewJWT creates a new JWT with the Secret, the ExpireDuration and a fake
+// clock. The options are applied after the clock option.
+func NewJWT(opts ...gincup.JWTOption) *JWT {
+	clock := NewClock(time.Now())
+	opts = append([]gincup.JWTOption{gincup.WithTimeFunc(clock.Now)}, opts...)
+
+	return &JWT{
+		JWT:   gincup.NewJWT(Secret, ExpireDuration, opts...),
+		Clock: clock,
+	}
+}
+
+// Token returns a valid token with the subject.
+func (j *JWT) Token(t testing.TB, sub string) string {
+	t.Helper()
+
+	return j.TokenWithClaims(t, map[string]any{"sub": sub})
+}
+
+// TokenWithClaims returns a valid token with the claims. The registered
+// claims that are not set are stamped as with gincup.GenerateTokenWithClaims.
+func (j *JWT) TokenWithClaims(t testing.TB, claims map[string]any) string {
+	t.Helper()
+
+	token, err := gincup.GenerateTokenWithClaims(j.JWT, claims)
+	if err != nil {
+		t.Fatalf("gincuptest: generate token: %v", err)
+	}
+	return token
+}
+
+// ScopedToken returns a valid token with the subject and the scopes in the
+// "scope" claim.
+func (j *JWT) ScopedToken(t testing.TB, sub string, scopes ...string) string {
+	t.Helper()
+
+	return j.TokenWithClaims(t, map[string]any{
+		"sub":   sub,
+		"scope": strings.Join(scopes, " "),
+	})
+}
+
+// ExpiredToken returns a token with the subject that expired a second ago.
+func (j *JWT) ExpiredToken(t testing.TB, sub string) string {
+	t.Helper()
+
+	now := j.Clock.Now()
+	issuedAt := now.Add(-ExpireDuration - time.Second).Unix()
+	return j.TokenWithClaims(t, map[string]any{
+		"sub":       sub,
+		"exp":       now.Add(-time.Second).Unix(),
+		"iat":       issuedAt,
+		"nbf":       issuedAt,
+		"auth_time": issuedAt,
+	})
+}
+
+// TamperedToken returns a token whose subject has been replaced by sub after
+// it was signed, so its signature is invalid.
+func (j *JWT) TamperedToken(t testing.TB, sub string) string {
+	t.Helper()
+
+	parts := strings.Split(j.Token(t, sub+"-original"), ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("gincuptest: decode token: %v", err)
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("gincuptest: decode token: %v", err)
+	}
+	claims["sub"] = sub
+
+	payload, err = json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("gincuptest: encode token: %v", err)
+	}
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	return strings.Join(parts, ".")
+}
+
+// Request returns a request carrying the token in the Authorization header.
+// An empty token sends no Authorization header.
+func (j *JWT) Request(method, target, token string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// ValidRequest returns a request carrying a valid token with the subject.
+func (j *JWT) ValidRequest(t testing.TB, method, target, sub string) *http.Request {
+	t.Helper()
+
+	return j.Request(method, target, j.Token(t, sub))
+}
+
+// ScopedRequest returns a request carrying a valid token with the subject
+// and the scopes.
+func (j *JWT) ScopedRequest(t testing.TB, method, target, sub string, scopes ...string) *http.Request {
+	t.Helper()
+
+	return j.Request(method, target, j.ScopedToken(t, sub, scopes...))
+}
+
+// ExpiredRequest returns a request carrying an expired token with the subject.
+func (j *JWT) ExpiredRequest(t testing.TB, method, target, sub string) *http.Request {
+	t.Helper()
+
+	return j.Request(method, target, j.ExpiredToken(t, sub))
+}
+
+// TamperedRequest returns a request carrying a tampered token with the subject.
+func (j *JWT) TamperedRequest(t testing.TB, method, target, sub string) *http.Request {
+	t.Helper()
+
+	return j.Request(method, target, j.TamperedToken(t, sub))
+}
+
+// Serve serves the request with the handler, usually a gin router, and
+// returns the recorded response.
+func Serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// AssertStatus reports an error if the status of the response is not the status.
+func AssertStatus(t testing.TB, w *httptest.ResponseRecorder, status int) bool {
+	t.Helper()
+
+	if w.Code != status {
+		t.Errorf("gincuptest: status is %d, want %d; body: %s", w.Code, status, w.Body.String())
+		return false
+	}
+	return true
+}
+
+// AssertUnauthorized reports an error if the response is not a 401
+// Unauthorized response with a WWW-Authenticate challenge.
+func AssertUnauthorized(t testing.TB, w *httptest.ResponseRecorder) bool {
+	t.Helper()
+
+	if !AssertStatus(t, w, http.StatusUnauthorized) {
+		return false
+	}
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("gincuptest: 401 response has no WWW-Authenticate header")
+		return false
+	}
+	return true
+}
+
+// AssertForbidden reports an error if the response is not a 403 Forbidden response.
+func AssertForbidden(t testing.TB, w *httptest.ResponseRecorder) bool {
+	t.Helper()
+
+	return AssertStatus(t, w, http.StatusForbidden)
+}
+
+// AssertTooManyRequests reports an error if the response is not a 429 Too
+// Many Requests response.
+func AssertTooManyRequests(t testing.TB, w *httptest.ResponseRecorder) bool {
+	t.Helper()
+
+	return AssertStatus(t, w, http.StatusTooManyRequests)
+}
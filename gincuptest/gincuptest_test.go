@@ -0,0 +1,113 @@
+package gincuptest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/okppop/gincup"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder records the errors reported by the assertion helpers.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func newRouter(j *JWT) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/orders", j.MiddlewareWithSubject(), j.RequireScopes("orders:read"), func(c *gin.Context) {
+		c.String(http.StatusOK, j.GetSubjectFromGinContext(c))
+	})
+	return router
+}
+
+func TestJWT(t *testing.T) {
+	j := NewJWT()
+	router := newRouter(j)
+
+	t.Run("valid token", func(t *testing.T) {
+		w := Serve(router, j.ScopedRequest(t, "GET", "/orders", "alice", "orders:read"))
+		AssertStatus(t, w, http.StatusOK)
+		assert.Equal(t, "alice", w.Body.String())
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		AssertUnauthorized(t, Serve(router, j.Request("GET", "/orders", "")))
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		AssertUnauthorized(t, Serve(router, j.ExpiredRequest(t, "GET", "/orders", "alice")))
+	})
+
+	t.Run("tampered token", func(t *testing.T) {
+		AssertUnauthorized(t, Serve(router, j.TamperedRequest(t, "GET", "/orders", "admin")))
+	})
+
+	t.Run("wrong scope", func(t *testing.T) {
+		AssertForbidden(t, Serve(router, j.ScopedRequest(t, "GET", "/orders", "alice", "orders:write")))
+		AssertForbidden(t, Serve(router, j.ValidRequest(t, "GET", "/orders", "alice")))
+	})
+
+	t.Run("clock", func(t *testing.T) {
+		token := j.ScopedToken(t, "alice", "orders:read")
+		AssertStatus(t, Serve(router, j.Request("GET", "/orders", token)), http.StatusOK)
+
+		j.Clock.Advance(ExpireDuration + time.Second)
+		AssertUnauthorized(t, Serve(router, j.Request("GET", "/orders", token)))
+
+		// tokens issued after the clock moved are valid
+		AssertStatus(t, Serve(router, j.ScopedRequest(t, "GET", "/orders", "alice", "orders:read")), http.StatusOK)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		j := NewJWT(gincup.WithIssuer("gincuptest"))
+		claims, err := j.ParseToken(t.Context(), j.Token(t, "alice"))
+		assert.NoError(t, err)
+		assert.Equal(t, "gincuptest", claims["iss"])
+	})
+}
+
+func TestAssertions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	router := gin.New()
+	router.GET("/limited", gincup.LimitMiddleware(1), func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Serve(router, NewJWT().Request("GET", "/limited", ""))
+	}()
+	<-started
+
+	w := Serve(router, NewJWT().Request("GET", "/limited", ""))
+	AssertTooManyRequests(t, w)
+	close(release)
+	<-done
+
+	t.Run("failures are reported", func(t *testing.T) {
+		r := &recorder{TB: t}
+		assert.False(t, AssertUnauthorized(r, w))
+		assert.False(t, AssertForbidden(r, w))
+		assert.Len(t, r.errors, 2)
+		assert.Contains(t, r.errors[0], "status is 429, want 401")
+	})
+}
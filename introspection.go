@@ -0,0 +1,346 @@
+package gincup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidClient              = errors.New("invalid client")
+	ErrIntrospectionTokenRequired = errors.New("token is required")
+	ErrIntrospectionFailed        = errors.New("token introspection failed")
+)
+
+// maxIntrospectionResponseSize is the maximum size of an introspection response.
+const maxIntrospectionResponseSize = 1 << 20
+
+// ClientCredentialsFunc reports whether the client credentials are valid.
+//
+// Client secrets can be stored as BcryptHash hashes and checked with BcryptVerify.
+type ClientCredentialsFunc func(clientID, clientSecret string) bool
+
+// IntrospectionHandler is an RFC 7662 token introspection endpoint backed by
+// the validation of the JWT instance.
+//
+// Clients authenticate with HTTP Basic authentication, or with the
+// "client_id" and "client_secret" form fields, and post the token in the
+// "token" form field. The response is {"active": false} for invalid, expired
+// or revoked tokens, and the claims of the token with "active": true otherwise.
+// The "token_type" is "DPoP" for tokens bound to a DPoP key, and "Bearer"
+// otherwise.
+//
+// If the client credentials are invalid, the handler will return a 401 Unauthorized status.
+// If the token is missing, the handler will return a 400 Bad Request status.
+//
+// If authenticate is nil, panic.
+func (j *JWT) IntrospectionHandler(authenticate ClientCredentialsFunc) gin.HandlerFunc {
+	if authenticate == nil {
+		panic("client authentication is required")
+	}
+
+	return func(c *gin.Context) {
+		clientID, clientSecret, ok := basicClientCredentials(c.Request)
+		if !ok {
+			clientID, clientSecret = c.PostForm("client_id"), c.PostForm("client_secret")
+		}
+		if clientID == "" || !authenticate(clientID, clientSecret) {
+			c.Header("WWW-Authenticate", formatChallenge("Basic", nil))
+			j.respondError(c, http.StatusUnauthorized, ErrInvalidClient)
+			return
+		}
+
+		token := c.PostForm("token")
+		if token == "" {
+			j.respondError(c, http.StatusBadRequest, ErrIntrospectionTokenRequired)
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+
+		claims, err := j.parseToken(c.Request.Context(), token)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"active": false})
+			return
+		}
+
+		response := gin.H{}
+		for name, value := range claims {
+			response[name] = value
+		}
+		response["active"] = true
+		response["token_type"] = "Bearer"
+		if dpopThumbprint(claims) != "" {
+			response["token_type"] = "DPoP"
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// basicClientCredentials returns the client credentials of the HTTP Basic
+// authentication, which are form-encoded as per RFC 6749 section 2.3.1.
+func basicClientCredentials(r *http.Request) (string, string, bool) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		return "", "", false
+	}
+
+	clientID, err := url.QueryUnescape(clientID)
+	if err != nil {
+		return "", "", false
+	}
+	clientSecret, err = url.QueryUnescape(clientSecret)
+	if err != nil {
+		return "", "", false
+	}
+	return clientID, clientSecret, true
+}
+
+// Introspector validates opaque tokens by calling a remote RFC 7662 token
+// introspection endpoint.
+//
+// Responses are cached for the cache TTL, but never past the expiration of
+// the token.
+type Introspector struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+	cacheTTL     time.Duration
+
+	// now returns the current time, replaced in tests
+	now func() time.Time
+
+	// front extracts tokens and writes the responses of rejected requests
+	front *JWT
+
+	mu    sync.Mutex
+	cache map[string]introspectionCacheEntry
+}
+
+type introspectionCacheEntry struct {
+	claims    jwt.MapClaims
+	expiresAt time.Time
+}
+
+// IntrospectorOption configures an Introspector.
+type IntrospectorOption func(*Introspector)
+
+// WithIntrospectionHTTPClient sets the HTTP client used to call the
+// introspection endpoint.
+//
+// The default is a client with a 10 seconds timeout.
+func WithIntrospectionHTTPClient(client *http.Client) IntrospectorOption {
+	return func(i *Introspector) {
+		i.client = client
+	}
+}
+
+// WithIntrospectionCacheTTL sets how long introspection responses are cached.
+// The default is 1 minute. A TTL of 0 disables the cache.
+func WithIntrospectionCacheTTL(ttl time.Duration) IntrospectorOption {
+	return func(i *Introspector) {
+		i.cacheTTL = ttl
+	}
+}
+
+// WithIntrospectionTokenExtractors sets where tokens are read from.
+//
+// The default is HeaderExtractor("Authorization", "Bearer").
+func WithIntrospectionTokenExtractors(extractors ...TokenExtractor) IntrospectorOption {
+	return func(i *Introspector) {
+		i.front.extractors = extractors
+	}
+}
+
+// WithIntrospectionErrorResponder sets the responder of rejected requests.
+//
+// The default is DefaultErrorResponder.
+func WithIntrospectionErrorResponder(responder ErrorResponder) IntrospectorOption {
+	return func(i *Introspector) {
+		i.front.responder = responder
+	}
+}
+
+// NewIntrospector creates a new Introspector that calls the endpoint with
+// the client credentials.
+//
+// If the endpoint is empty, panic.
+func NewIntrospector(endpoint, clientID, clientSecret string, opts ...IntrospectorOption) *Introspector {
+	if endpoint == "" {
+		panic("endpoint is required")
+	}
+
+	i := &Introspector{
+		endpoint:     endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		cacheTTL:     1 * time.Minute,
+		now:          time.Now,
+		front:        &JWT{keys: map[string]*jwtKey{}},
+		cache:        map[string]introspectionCacheEntry{},
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Introspect returns the claims of an active token.
+//
+// If the token is not active, return ErrJWTInvalidToken.
+// If the endpoint cannot be called, return an error wrapping ErrIntrospectionFailed.
+func (i *Introspector) Introspect(ctx context.Context, token string) (jwt.MapClaims, error) {
+	sum := sha256.Sum256([]byte(token))
+	cacheKey := hex.EncodeToString(sum[:])
+
+	if entry, ok := i.cached(cacheKey); ok {
+		if entry.claims == nil {
+			return nil, ErrJWTInvalidToken
+		}
+		return entry.claims, nil
+	}
+
+	claims, err := i.call(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	active, _ := claims["active"].(bool)
+	if !active {
+		i.store(cacheKey, nil, i.now().Add(i.cacheTTL))
+		return nil, ErrJWTInvalidToken
+	}
+
+	expiresAt := i.now().Add(i.cacheTTL)
+	if exp, ok := numericDateClaim(claims, "exp"); ok {
+		if !exp.After(i.now()) {
+			return nil, ErrJWTTokenExpired
+		}
+		if exp.Before(expiresAt) {
+			expiresAt = exp
+		}
+	}
+	i.store(cacheKey, claims, expiresAt)
+	return claims, nil
+}
+
+// Middleware is a middleware that validates an opaque token with the
+// introspection endpoint and sets the subject and the claims to the context
+// as with MiddlewareWithSubject.
+//
+// If the token is missing, not active or bound to a DPoP key, the middleware will return a 401 Unauthorized status.
+// If the endpoint cannot be called, the middleware will return a 503 Service Unavailable status.
+func (i *Introspector) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := i.front.extractToken(c)
+		if err != nil {
+			i.front.abort(c, err)
+			return
+		}
+
+		claims, err := i.Introspect(c.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, ErrIntrospectionFailed) {
+				i.front.respondError(c, http.StatusServiceUnavailable, err)
+				return
+			}
+			i.front.abort(c, err)
+			return
+		}
+		// a token bound to a DPoP key is not a bearer token
+		if claims["token_type"] == "DPoP" || dpopThumbprint(claims) != "" {
+			i.front.abort(c, ErrDPoPBoundToken)
+			return
+		}
+
+		if err := setClaims(c, claims); err != nil {
+			i.front.abort(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// call calls the introspection endpoint.
+func (i *Introspector) call(ctx context.Context, token string) (jwt.MapClaims, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Join(ErrIntrospectionFailed, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, errors.Join(ErrIntrospectionFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrIntrospectionFailed
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIntrospectionResponseSize))
+	if err != nil {
+		return nil, errors.Join(ErrIntrospectionFailed, err)
+	}
+
+	var claims jwt.MapClaims
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil || claims == nil {
+		return nil, ErrIntrospectionFailed
+	}
+	return claims, nil
+}
+
+// cached returns the cached response for the token hash.
+func (i *Introspector) cached(cacheKey string) (introspectionCacheEntry, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	entry, ok := i.cache[cacheKey]
+	if !ok {
+		return introspectionCacheEntry{}, false
+	}
+	if i.now().After(entry.expiresAt) {
+		delete(i.cache, cacheKey)
+		return introspectionCacheEntry{}, false
+	}
+	return entry, true
+}
+
+// store caches the response for the token hash. A nil claims means
+// the token is not active.
+func (i *Introspector) store(cacheKey string, claims jwt.MapClaims, expiresAt time.Time) {
+	if i.cacheTTL <= 0 {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	for key, entry := range i.cache {
+		if now.After(entry.expiresAt) {
+			delete(i.cache, key)
+		}
+	}
+	i.cache[cacheKey] = introspectionCacheEntry{claims: claims, expiresAt: expiresAt}
+}
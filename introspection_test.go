@@ -0,0 +1,249 @@
+package gincup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newIntrospectionServer(t *testing.T, j *JWT, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	router := gin.New()
+	handler := j.IntrospectionHandler(func(clientID, clientSecret string) bool {
+		return clientID == "api" && clientSecret == "s3cret"
+	})
+	router.POST("/introspect", func(c *gin.Context) {
+		if calls != nil {
+			calls.Add(1)
+		}
+		handler(c)
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestIntrospectionHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	j := NewJWT("secret", 1*time.Hour, WithIssuer("https://auth.example.com"))
+	server := newIntrospectionServer(t, j, nil)
+
+	introspect := func(token string, setAuth func(*http.Request)) (*http.Response, map[string]any) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		setAuth(req)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp, body
+	}
+	basicAuth := func(req *http.Request) {
+		req.SetBasicAuth("api", "s3cret")
+	}
+
+	t.Run("active token", func(t *testing.T) {
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		resp, body := introspect(token, basicAuth)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+		assert.Equal(t, true, body["active"])
+		assert.Equal(t, "test123", body["sub"])
+		assert.Equal(t, "https://auth.example.com", body["iss"])
+		assert.Equal(t, "Bearer", body["token_type"])
+		assert.NotEmpty(t, body["exp"])
+	})
+
+	t.Run("DPoP-bound token", func(t *testing.T) {
+		token, err := j.GenerateDPoPTokenAndSetSubject("test123", "jkt")
+		assert.NoError(t, err)
+
+		resp, body := introspect(token, basicAuth)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, true, body["active"])
+		assert.Equal(t, "DPoP", body["token_type"])
+		assert.Equal(t, map[string]any{"jkt": "jkt"}, body["cnf"])
+	})
+
+	t.Run("inactive token", func(t *testing.T) {
+		other := NewJWT("other", 1*time.Hour)
+		token, err := other.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		resp, body := introspect(token, basicAuth)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, map[string]any{"active": false}, body)
+	})
+
+	t.Run("form client credentials", func(t *testing.T) {
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		form := url.Values{"token": {token}, "client_id": {"api"}, "client_secret": {"s3cret"}}
+		resp, err := http.PostForm(server.URL+"/introspect", form)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("invalid client", func(t *testing.T) {
+		resp, body := introspect("token", func(req *http.Request) {
+			req.SetBasicAuth("api", "wrong")
+		})
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, "Basic", resp.Header.Get("WWW-Authenticate"))
+		assert.Equal(t, ErrInvalidClient.Error(), body["message"])
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		resp, body := introspect("", basicAuth)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, ErrIntrospectionTokenRequired.Error(), body["message"])
+	})
+
+	t.Run("nil authenticate panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			j.IntrospectionHandler(nil)
+		})
+	})
+}
+
+func TestIntrospector(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	j := NewJWT("secret", 1*time.Hour)
+
+	newRouter := func(i *Introspector) *gin.Engine {
+		router := gin.New()
+		router.GET("/test", i.Middleware(), func(c *gin.Context) {
+			sub, _ := c.Get(subjectContextKey)
+			c.String(http.StatusOK, sub.(string))
+		})
+		return router
+	}
+	request := func(router *gin.Engine, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("active token is cached", func(t *testing.T) {
+		var calls atomic.Int32
+		server := newIntrospectionServer(t, j, &calls)
+		router := newRouter(NewIntrospector(server.URL+"/introspect", "api", "s3cret"))
+
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		for range 3 {
+			w := request(router, token)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "test123", w.Body.String())
+		}
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("DPoP-bound token is not a bearer token", func(t *testing.T) {
+		server := newIntrospectionServer(t, j, nil)
+		router := newRouter(NewIntrospector(server.URL+"/introspect", "api", "s3cret"))
+
+		token, err := j.GenerateDPoPTokenAndSetSubject("test123", "jkt")
+		assert.NoError(t, err)
+
+		w := request(router, token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("cache disabled", func(t *testing.T) {
+		var calls atomic.Int32
+		server := newIntrospectionServer(t, j, &calls)
+		router := newRouter(NewIntrospector(server.URL+"/introspect", "api", "s3cret", WithIntrospectionCacheTTL(0)))
+
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		request(router, token)
+		request(router, token)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("inactive token", func(t *testing.T) {
+		var calls atomic.Int32
+		server := newIntrospectionServer(t, j, &calls)
+		router := newRouter(NewIntrospector(server.URL+"/introspect", "api", "s3cret"))
+
+		w := request(router, "opaque")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+
+		request(router, "opaque")
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		server := newIntrospectionServer(t, j, nil)
+		router := newRouter(NewIntrospector(server.URL+"/introspect", "api", "s3cret"))
+
+		w := request(router, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("invalid client credentials", func(t *testing.T) {
+		server := newIntrospectionServer(t, j, nil)
+		i := NewIntrospector(server.URL+"/introspect", "api", "wrong")
+
+		_, err := i.Introspect(context.Background(), "opaque")
+		assert.ErrorIs(t, err, ErrIntrospectionFailed)
+
+		w := request(newRouter(i), "opaque")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	t.Run("cache does not outlive the token", func(t *testing.T) {
+		now := time.Now()
+		clock := func() time.Time { return now }
+		issuer := NewJWT("secret", 1*time.Minute, WithTimeFunc(clock))
+		var calls atomic.Int32
+		server := newIntrospectionServer(t, issuer, &calls)
+		i := NewIntrospector(server.URL+"/introspect", "api", "s3cret", WithIntrospectionCacheTTL(1*time.Hour))
+		i.now = clock
+
+		token, err := issuer.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		_, err = i.Introspect(context.Background(), token)
+		assert.NoError(t, err)
+		_, err = i.Introspect(context.Background(), token)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+
+		now = now.Add(2 * time.Minute)
+		_, err = i.Introspect(context.Background(), token)
+		assert.ErrorIs(t, err, ErrJWTInvalidToken)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("empty endpoint panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewIntrospector("", "api", "s3cret")
+		})
+	})
+}
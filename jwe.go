@@ -0,0 +1,178 @@
+package gincup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrJWTDecryptionKeyMissing = errors.New("decryption key is missing")
+)
+
+// jweEncryption encrypts signed tokens as compact JWE (RFC 7516) with
+// A256GCM content encryption.
+//
+// The content encryption key is either the shared key itself ("dir") or a
+// random key encrypted with RSA-OAEP ("RSA-OAEP").
+type jweEncryption struct {
+	alg           string
+	sharedKey     []byte
+	rsaPublicKey  *rsa.PublicKey
+	rsaPrivateKey *rsa.PrivateKey
+}
+
+// jweHeader is the protected header of an encrypted token.
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Cty string `json:"cty,omitempty"`
+}
+
+// WithEncryption encrypts generated tokens as compact JWE with A256GCM,
+// nesting the signed token, so that the claims are only readable with the
+// decryption key. The middlewares only accept encrypted tokens.
+//
+// The key is a 32 bytes []byte shared key ("dir"), an *rsa.PrivateKey
+// ("RSA-OAEP") that encrypts and decrypts, or an *rsa.PublicKey ("RSA-OAEP")
+// that only encrypts tokens for another service.
+//
+// If the key is not supported, panic.
+func WithEncryption(key interface{}) JWTOption {
+	var enc jweEncryption
+	switch k := key.(type) {
+	case []byte:
+		if len(k) != 32 {
+			panic("encryption key must be 32 bytes long")
+		}
+		enc = jweEncryption{alg: "dir", sharedKey: k}
+	case *rsa.PrivateKey:
+		enc = jweEncryption{alg: "RSA-OAEP", rsaPublicKey: &k.PublicKey, rsaPrivateKey: k}
+	case *rsa.PublicKey:
+		enc = jweEncryption{alg: "RSA-OAEP", rsaPublicKey: k}
+	default:
+		panic(ErrJWTUnsupportedKey.Error())
+	}
+
+	return func(j *JWT) {
+		j.encryption = &enc
+	}
+}
+
+// encrypt encrypts a signed token.
+func (e *jweEncryption) encrypt(token string) (string, error) {
+	header, err := json.Marshal(jweHeader{Alg: e.alg, Enc: "A256GCM", Cty: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	var cek, encryptedKey []byte
+	switch e.alg {
+	case "dir":
+		cek = e.sharedKey
+	case "RSA-OAEP":
+		cek = make([]byte, 32)
+		if _, err := rand.Read(cek); err != nil {
+			return "", err
+		}
+		encryptedKey, err = rsa.EncryptOAEP(sha1.New(), rand.Reader, e.rsaPublicKey, cek, nil)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	// the tag is appended to the ciphertext
+	sealed := gcm.Seal(nil, iv, []byte(token), []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		protected,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+// decrypt decrypts an encrypted token and returns the nested signed token.
+func (e *jweEncryption) decrypt(token string) (string, error) {
+	if e.alg == "RSA-OAEP" && e.rsaPrivateKey == nil {
+		return "", ErrJWTDecryptionKeyMissing
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return "", ErrJWTInvalidToken
+	}
+
+	decoded := make([][]byte, 5)
+	for i, part := range parts {
+		b, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return "", ErrJWTInvalidToken
+		}
+		decoded[i] = b
+	}
+
+	var header jweHeader
+	if err := json.Unmarshal(decoded[0], &header); err != nil {
+		return "", ErrJWTInvalidToken
+	}
+	if header.Alg != e.alg || header.Enc != "A256GCM" {
+		return "", ErrJWTInvalidToken
+	}
+
+	var cek []byte
+	switch e.alg {
+	case "dir":
+		if len(decoded[1]) != 0 {
+			return "", ErrJWTInvalidToken
+		}
+		cek = e.sharedKey
+	case "RSA-OAEP":
+		key, err := rsa.DecryptOAEP(sha1.New(), nil, e.rsaPrivateKey, decoded[1], nil)
+		if err != nil || len(key) != 32 {
+			return "", ErrJWTInvalidToken
+		}
+		cek = key
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	if len(decoded[2]) != gcm.NonceSize() || len(decoded[4]) != gcm.Overhead() {
+		return "", ErrJWTInvalidToken
+	}
+
+	plaintext, err := gcm.Open(nil, decoded[2], append(decoded[3], decoded[4]...), []byte(parts[0]))
+	if err != nil {
+		return "", ErrJWTInvalidToken
+	}
+	return string(plaintext), nil
+}
+
+// newGCM returns an AES-GCM cipher with the key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,134 @@
+package gincup
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTEncryption(t *testing.T) {
+	sharedKey := make([]byte, 32)
+	_, err := rand.Read(sharedKey)
+	assert.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	t.Run("dir", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour, WithEncryption(sharedKey))
+
+		token, err := GenerateTokenWithClaims(j, jwt.MapClaims{"sub": "test123", "email": "test@example.com"})
+		assert.NoError(t, err)
+
+		parts := strings.Split(token, ".")
+		assert.Len(t, parts, 5)
+		assert.Empty(t, parts[1])
+		assert.NotContains(t, token, base64.RawURLEncoding.EncodeToString([]byte("test@example.com")))
+
+		header, err := base64.RawURLEncoding.DecodeString(parts[0])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"alg":"dir","enc":"A256GCM","cty":"JWT"}`, string(header))
+
+		sub, err := j.validateTokenAndGetSubject(token)
+		assert.NoError(t, err)
+		assert.Equal(t, "test123", sub)
+	})
+
+	t.Run("rsa-oaep", func(t *testing.T) {
+		issuer := NewJWT("secret", 1*time.Hour, WithEncryption(&rsaKey.PublicKey))
+		verifier := NewJWT("secret", 1*time.Hour, WithEncryption(rsaKey))
+
+		token, err := issuer.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+		assert.Len(t, strings.Split(token, "."), 5)
+
+		sub, err := verifier.validateTokenAndGetSubject(token)
+		assert.NoError(t, err)
+		assert.Equal(t, "test123", sub)
+
+		// the public key cannot decrypt
+		_, err = issuer.validateTokenAndGetSubject(token)
+		assert.ErrorIs(t, err, ErrJWTDecryptionKeyMissing)
+	})
+
+	t.Run("nested token is signed", func(t *testing.T) {
+		enc := &jweEncryption{alg: "dir", sharedKey: sharedKey}
+
+		// encrypted with the right key, but signed with another secret
+		inner, err := NewJWT("other", 1*time.Hour).GenerateToken()
+		assert.NoError(t, err)
+		token, err := enc.encrypt(inner)
+		assert.NoError(t, err)
+
+		j := NewJWT("secret", 1*time.Hour, WithEncryption(sharedKey))
+		assert.ErrorIs(t, j.validateToken(token), ErrJWTInvalidToken)
+	})
+
+	t.Run("rejected tokens", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour, WithEncryption(sharedKey))
+
+		// plain signed token
+		plain, err := NewJWT("secret", 1*time.Hour).GenerateToken()
+		assert.NoError(t, err)
+		assert.ErrorIs(t, j.validateToken(plain), ErrJWTInvalidToken)
+
+		token, err := j.GenerateToken()
+		assert.NoError(t, err)
+
+		// wrong key
+		otherKey := make([]byte, 32)
+		other := NewJWT("secret", 1*time.Hour, WithEncryption(otherKey))
+		assert.ErrorIs(t, other.validateToken(token), ErrJWTInvalidToken)
+
+		// tampered ciphertext
+		parts := strings.Split(token, ".")
+		ciphertext, err := base64.RawURLEncoding.DecodeString(parts[3])
+		assert.NoError(t, err)
+		ciphertext[0] ^= 1
+		parts[3] = base64.RawURLEncoding.EncodeToString(ciphertext)
+		assert.ErrorIs(t, j.validateToken(strings.Join(parts, ".")), ErrJWTInvalidToken)
+
+		// tampered header
+		header, err := json.Marshal(jweHeader{Alg: "dir", Enc: "A256GCM"})
+		assert.NoError(t, err)
+		parts = strings.Split(token, ".")
+		parts[0] = base64.RawURLEncoding.EncodeToString(header)
+		assert.ErrorIs(t, j.validateToken(strings.Join(parts, ".")), ErrJWTInvalidToken)
+	})
+
+	t.Run("middleware", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour, WithEncryption(sharedKey))
+
+		router := gin.New()
+		router.Use(j.MiddlewareWithSubject())
+		router.GET("/test", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"subject": j.GetSubjectFromGinContext(c)})
+		})
+
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subject":"test123"}`, w.Body.String())
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		assert.Panics(t, func() { WithEncryption([]byte("short")) })
+		assert.Panics(t, func() { WithEncryption("secret") })
+	})
+}
@@ -0,0 +1,243 @@
+package gincup
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrJWKInvalid = errors.New("invalid JSON Web Key")
+)
+
+// JSONWebKey is a public key in JSON Web Key format (RFC 7517).
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is a set of JSON Web Keys.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKey converts an RSA, ECDSA or Ed25519 public key to a JSON Web Key
+// used to verify signatures.
+//
+// If the key is not supported, return ErrJWTUnsupportedKey.
+func NewJSONWebKey(kid string, key crypto.PublicKey) (JSONWebKey, error) {
+	method, err := signingMethodForPublicKey(key)
+	if err != nil {
+		return JSONWebKey{}, err
+	}
+
+	jwk := JSONWebKey{Kid: kid, Use: "sig", Alg: method.Alg()}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// the uncompressed point is 0x04 || X || Y with fixed size coordinates
+		pub, err := k.ECDH()
+		if err != nil {
+			return JSONWebKey{}, ErrJWTUnsupportedKey
+		}
+		point := pub.Bytes()[1:]
+		size := len(point) / 2
+		jwk.Kty = "EC"
+		jwk.Crv = k.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(point[:size])
+		jwk.Y = base64.RawURLEncoding.EncodeToString(point[size:])
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(k)
+	}
+	return jwk, nil
+}
+
+// PublicKey returns the public key of the JSON Web Key.
+//
+// If the key type is not supported, return ErrJWTUnsupportedKey.
+// If the key is malformed, return ErrJWKInvalid.
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return nil, ErrJWKInvalid
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, ErrJWKInvalid
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, ErrJWTUnsupportedKey
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != size {
+			return nil, ErrJWKInvalid
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil || len(y) != size {
+			return nil, ErrJWKInvalid
+		}
+		// ecdh checks that the point is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+			return nil, ErrJWKInvalid
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, ErrJWTUnsupportedKey
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, ErrJWKInvalid
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, ErrJWTUnsupportedKey
+	}
+}
+
+// JWKS returns the public keys of the keyring as a JSON Web Key Set.
+//
+// HMAC secrets are never published. The active signing key comes first,
+// followed by the verification keys ordered by key id.
+func (j *JWT) JWKS() JSONWebKeySet {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	keys := make([]*jwtKey, 0, len(j.keys))
+	for _, k := range j.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if keys[a] == j.activeKey || keys[b] == j.activeKey {
+			return keys[a] == j.activeKey
+		}
+		return keys[a].id < keys[b].id
+	})
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, k := range keys {
+		jwk, err := NewJSONWebKey(k.id, k.verifyKey)
+		if err != nil {
+			// HMAC secret
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// JWKSHandler is a handler that serves the public keys of the keyring as a
+// JSON Web Key Set, usually mounted at "/.well-known/jwks.json".
+//
+// The response can be cached by verifiers for maxAge, and carries an ETag so
+// that conditional requests are answered with a 304 Not Modified status.
+// The keys are read on every request, so rotations are published immediately.
+func (j *JWT) JWKSHandler(maxAge time.Duration) gin.HandlerFunc {
+	cacheControl := "public, max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+
+	return func(c *gin.Context) {
+		body, err := json.Marshal(j.JWKS())
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+		c.Header("Cache-Control", cacheControl)
+		c.Header("ETag", etag)
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		c.Data(http.StatusOK, "application/json", body)
+	}
+}
+
+// verificationKeys converts the set to keyring entries indexed by key id.
+//
+// Keys that are not used for signatures, that are not supported or whose
+// "alg" does not match their key type are skipped.
+func (s JSONWebKeySet) verificationKeys() map[string]*jwtKey {
+	keys := make(map[string]*jwtKey, len(s.Keys))
+	for _, jwk := range s.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		key, err := newJWTKey(jwk.Kid, pub)
+		if err != nil {
+			continue
+		}
+		if jwk.Alg != "" {
+			method := jwt.GetSigningMethod(jwk.Alg)
+			if method == nil || !slices.Contains(compatibleAlgorithms(key.verifyKey), jwk.Alg) {
+				continue
+			}
+			key.method = method
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys
+}
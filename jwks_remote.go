@@ -0,0 +1,250 @@
+package gincup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJWKSFetchFailed = errors.New("failed to fetch JWKS")
+)
+
+// maxJWKSResponseSize is the maximum size of a fetched JSON Web Key Set.
+const maxJWKSResponseSize = 1 << 20
+
+// RemoteJWKS is a JSON Web Key Set fetched from a URL.
+//
+// The keys are cached and refreshed in the background. When a token carries
+// an unknown "kid", the set is fetched again, at most once per minimum
+// refetch interval, so that a stream of bogus tokens cannot flood the issuer.
+type RemoteJWKS struct {
+	url                string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefetchInterval time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]*jwtKey
+	etag      string
+	lastFetch time.Time
+
+	// fetchMu serializes fetches
+	fetchMu sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// RemoteJWKSOption configures a RemoteJWKS.
+type RemoteJWKSOption func(*RemoteJWKS)
+
+// WithJWKSHTTPClient sets the HTTP client used to fetch the key set.
+//
+// The default is a client with a 10 seconds timeout.
+func WithJWKSHTTPClient(client *http.Client) RemoteJWKSOption {
+	return func(r *RemoteJWKS) {
+		r.client = client
+	}
+}
+
+// WithJWKSRefreshInterval sets how often the key set is refreshed in the
+// background. The default is 15 minutes.
+func WithJWKSRefreshInterval(d time.Duration) RemoteJWKSOption {
+	return func(r *RemoteJWKS) {
+		r.refreshInterval = d
+	}
+}
+
+// WithJWKSMinRefetchInterval sets the minimum time between two fetches
+// triggered by unknown key ids. The default is 1 minute.
+func WithJWKSMinRefetchInterval(d time.Duration) RemoteJWKSOption {
+	return func(r *RemoteJWKS) {
+		r.minRefetchInterval = d
+	}
+}
+
+// NewRemoteJWKS creates a RemoteJWKS and fetches the key set once.
+//
+// A background goroutine refreshes the keys until Close is called.
+//
+// If the url is empty, panic.
+// If the refresh interval is less than or equal to 0, panic.
+// If the first fetch fails, return an error.
+func NewRemoteJWKS(url string, opts ...RemoteJWKSOption) (*RemoteJWKS, error) {
+	if url == "" {
+		panic("url is required")
+	}
+
+	r := &RemoteJWKS{
+		url:                url,
+		client:             &http.Client{Timeout: 10 * time.Second},
+		refreshInterval:    15 * time.Minute,
+		minRefetchInterval: 1 * time.Minute,
+		keys:               map[string]*jwtKey{},
+		done:               make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.refreshInterval <= 0 {
+		panic("refresh interval must be greater than 0")
+	}
+
+	if err := r.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	go r.refreshLoop()
+	return r, nil
+}
+
+// NewJWTWithRemoteJWKS creates a new JWT instance that verifies tokens with
+// the keys of a remote JSON Web Key Set.
+//
+// The instance can only verify tokens; Middleware and MiddlewareWithSubject
+// work as with any other JWT instance.
+//
+// If the key set is nil, panic.
+func NewJWTWithRemoteJWKS(r *RemoteJWKS, opts ...JWTOption) *JWT {
+	if r == nil {
+		panic("remote JWKS is required")
+	}
+
+	j := &JWT{
+		keys:   map[string]*jwtKey{},
+		remote: r,
+	}
+	j.applyOptions(opts)
+	return j
+}
+
+// Refresh fetches the key set now.
+//
+// If the fetch fails, the cached keys are kept and an error is returned.
+func (r *RemoteJWKS) Refresh(ctx context.Context) error {
+	r.fetchMu.Lock()
+	defer r.fetchMu.Unlock()
+
+	return r.fetch(ctx)
+}
+
+// Close stops the background refresh.
+func (r *RemoteJWKS) Close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// refreshLoop refreshes the key set until the RemoteJWKS is closed.
+func (r *RemoteJWKS) refreshLoop() {
+	ticker := time.NewTicker(r.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			// keep serving the cached keys if the issuer is unavailable
+			_ = r.Refresh(context.Background())
+		}
+	}
+}
+
+// fetch fetches the key set. The caller must hold fetchMu.
+func (r *RemoteJWKS) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return errors.Join(ErrJWKSFetchFailed, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	r.mu.RLock()
+	etag := r.etag
+	r.mu.RUnlock()
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return errors.Join(ErrJWKSFetchFailed, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		r.mu.Lock()
+		r.lastFetch = time.Now()
+		r.mu.Unlock()
+		return nil
+	case http.StatusOK:
+	default:
+		return ErrJWKSFetchFailed
+	}
+
+	var set JSONWebKeySet
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSResponseSize)).Decode(&set); err != nil {
+		return errors.Join(ErrJWKSFetchFailed, err)
+	}
+
+	r.mu.Lock()
+	r.keys = set.verificationKeys()
+	r.etag = resp.Header.Get("ETag")
+	r.lastFetch = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// lookupKey returns the key with the key id.
+//
+// If the key is unknown and the set has not been fetched within the minimum
+// refetch interval, the set is fetched once more.
+func (r *RemoteJWKS) lookupKey(kid string) (*jwtKey, error) {
+	if key, ok := r.cachedKey(kid); ok {
+		return key, nil
+	}
+
+	r.fetchMu.Lock()
+	defer r.fetchMu.Unlock()
+
+	// another request may have fetched the key while waiting for the lock
+	if key, ok := r.cachedKey(kid); ok {
+		return key, nil
+	}
+
+	r.mu.RLock()
+	recent := time.Since(r.lastFetch) < r.minRefetchInterval
+	r.mu.RUnlock()
+	if recent {
+		return nil, ErrJWTKeyNotFound
+	}
+
+	if err := r.fetch(context.Background()); err != nil {
+		// do not retry before the minimum refetch interval
+		r.mu.Lock()
+		r.lastFetch = time.Now()
+		r.mu.Unlock()
+		return nil, err
+	}
+
+	if key, ok := r.cachedKey(kid); ok {
+		return key, nil
+	}
+	return nil, ErrJWTKeyNotFound
+}
+
+// cachedKey returns the cached key with the key id.
+func (r *RemoteJWKS) cachedKey(kid string) (*jwtKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[kid]
+	return key, ok
+}
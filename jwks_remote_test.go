@@ -0,0 +1,144 @@
+package gincup
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// newJWKSServer serves the JWKS of the issuer and counts the requests.
+func newJWKSServer(t *testing.T, issuer *JWT) (*httptest.Server, *atomic.Int32) {
+	var count atomic.Int32
+
+	router := gin.New()
+	router.GET("/jwks.json", func(c *gin.Context) {
+		count.Add(1)
+		c.Next()
+	}, issuer.JWKSHandler(time.Minute))
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, &count
+}
+
+func TestRemoteJWKS(t *testing.T) {
+	key1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	key2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	t.Run("middleware", func(t *testing.T) {
+		issuer := NewJWTWithPrivateKey(key1, 1*time.Hour)
+		assert.NoError(t, issuer.RotateKey("k1", key1))
+		server, _ := newJWKSServer(t, issuer)
+
+		remote, err := NewRemoteJWKS(server.URL + "/jwks.json")
+		assert.NoError(t, err)
+		defer remote.Close()
+
+		verifier := NewJWTWithRemoteJWKS(remote)
+
+		router := gin.New()
+		router.Use(verifier.MiddlewareWithSubject())
+		router.GET("/test", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"subject": verifier.GetSubjectFromGinContext(c)})
+		})
+
+		token, err := issuer.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subject":"test123"}`, w.Body.String())
+
+		_, err = verifier.GenerateToken()
+		assert.ErrorIs(t, err, ErrJWTSigningKeyMissing)
+	})
+
+	t.Run("unknown kid triggers refetch", func(t *testing.T) {
+		issuer := NewJWTWithPrivateKey(key1, 1*time.Hour)
+		assert.NoError(t, issuer.RotateKey("k1", key1))
+		server, count := newJWKSServer(t, issuer)
+
+		remote, err := NewRemoteJWKS(server.URL+"/jwks.json", WithJWKSMinRefetchInterval(0))
+		assert.NoError(t, err)
+		defer remote.Close()
+		verifier := NewJWTWithRemoteJWKS(remote)
+		assert.Equal(t, int32(1), count.Load())
+
+		assert.NoError(t, issuer.RotateKey("k2", key2))
+		token, err := issuer.GenerateToken()
+		assert.NoError(t, err)
+
+		assert.NoError(t, verifier.validateToken(token))
+		assert.Equal(t, int32(2), count.Load())
+
+		// the new key is cached
+		assert.NoError(t, verifier.validateToken(token))
+		assert.Equal(t, int32(2), count.Load())
+	})
+
+	t.Run("refetch is rate limited", func(t *testing.T) {
+		issuer := NewJWTWithPrivateKey(key1, 1*time.Hour)
+		assert.NoError(t, issuer.RotateKey("k1", key1))
+		server, count := newJWKSServer(t, issuer)
+
+		remote, err := NewRemoteJWKS(server.URL+"/jwks.json", WithJWKSMinRefetchInterval(time.Hour))
+		assert.NoError(t, err)
+		defer remote.Close()
+		verifier := NewJWTWithRemoteJWKS(remote)
+
+		bogus := NewJWT("secret", 1*time.Hour)
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, bogus.RotateKey(string(rune('a'+i)), "secret"))
+			token, err := bogus.GenerateToken()
+			assert.NoError(t, err)
+			assert.ErrorIs(t, verifier.validateToken(token), ErrJWTInvalidToken)
+		}
+
+		assert.Equal(t, int32(1), count.Load())
+	})
+
+	t.Run("background refresh", func(t *testing.T) {
+		issuer := NewJWTWithPrivateKey(key1, 1*time.Hour)
+		assert.NoError(t, issuer.RotateKey("k1", key1))
+		server, count := newJWKSServer(t, issuer)
+
+		remote, err := NewRemoteJWKS(server.URL+"/jwks.json",
+			WithJWKSRefreshInterval(10*time.Millisecond),
+			WithJWKSMinRefetchInterval(time.Hour),
+		)
+		assert.NoError(t, err)
+		defer remote.Close()
+		verifier := NewJWTWithRemoteJWKS(remote)
+
+		assert.NoError(t, issuer.RotateKey("k2", key2))
+		token, err := issuer.GenerateToken()
+		assert.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			return verifier.validateToken(token) == nil
+		}, time.Second, 10*time.Millisecond)
+		assert.Greater(t, count.Load(), int32(1))
+	})
+
+	t.Run("fetch failure", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		_, err := NewRemoteJWKS(server.URL)
+		assert.ErrorIs(t, err, ErrJWKSFetchFailed)
+	})
+}
@@ -0,0 +1,143 @@
+package gincup
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJSONWebKey(t *testing.T) {
+	t.Run("rsa", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+
+		jwk, err := NewJSONWebKey("rsa-1", key.Public())
+		assert.NoError(t, err)
+		assert.Equal(t, "RSA", jwk.Kty)
+		assert.Equal(t, "rsa-1", jwk.Kid)
+		assert.Equal(t, "RS256", jwk.Alg)
+		assert.Equal(t, "sig", jwk.Use)
+		assert.Equal(t, "AQAB", jwk.E)
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, new(big.Int).SetBytes(n).Cmp(key.N))
+	})
+
+	t.Run("ecdsa", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		assert.NoError(t, err)
+
+		jwk, err := NewJSONWebKey("ec-1", key.Public())
+		assert.NoError(t, err)
+		assert.Equal(t, "EC", jwk.Kty)
+		assert.Equal(t, "P-384", jwk.Crv)
+		assert.Equal(t, "ES384", jwk.Alg)
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		assert.NoError(t, err)
+		assert.Len(t, x, 48)
+		assert.Equal(t, 0, new(big.Int).SetBytes(x).Cmp(key.X))
+	})
+
+	t.Run("ed25519", func(t *testing.T) {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(t, err)
+
+		jwk, err := NewJSONWebKey("ed-1", pub)
+		assert.NoError(t, err)
+		assert.Equal(t, "OKP", jwk.Kty)
+		assert.Equal(t, "Ed25519", jwk.Crv)
+		assert.Equal(t, "EdDSA", jwk.Alg)
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(pub), jwk.X)
+	})
+
+	t.Run("unsupported key", func(t *testing.T) {
+		_, err := NewJSONWebKey("hmac", []byte("secret"))
+		assert.ErrorIs(t, err, ErrJWTUnsupportedKey)
+	})
+}
+
+func TestJSONWebKeyPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	assert.NoError(t, err)
+
+	jwk, err := NewJSONWebKey("ec", key.Public())
+	assert.NoError(t, err)
+
+	pub, err := jwk.PublicKey()
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(pub))
+
+	jwk.Y = jwk.X
+	_, err = jwk.PublicKey()
+	assert.ErrorIs(t, err, ErrJWKInvalid)
+
+	_, err = JSONWebKey{Kty: "oct"}.PublicKey()
+	assert.ErrorIs(t, err, ErrJWTUnsupportedKey)
+}
+
+func TestJWTJWKSHandler(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	j := NewJWT("secret", 1*time.Hour)
+	assert.NoError(t, j.RotateKey("old", oldKey))
+
+	router := gin.New()
+	router.GET("/.well-known/jwks.json", j.JWKSHandler(10*time.Minute))
+
+	fetch := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := fetch("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=600", w.Header().Get("Cache-Control"))
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+
+	var set JSONWebKeySet
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	// the HMAC secret is not published
+	assert.Len(t, set.Keys, 1)
+	assert.Equal(t, "old", set.Keys[0].Kid)
+
+	t.Run("not modified", func(t *testing.T) {
+		w := fetch(w.Header().Get("ETag"))
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("rotation is published", func(t *testing.T) {
+		assert.NoError(t, j.RotateKey("new", newKey))
+
+		w2 := fetch(w.Header().Get("ETag"))
+		assert.Equal(t, http.StatusOK, w2.Code)
+
+		var set JSONWebKeySet
+		assert.NoError(t, json.Unmarshal(w2.Body.Bytes(), &set))
+		assert.Len(t, set.Keys, 2)
+		assert.Equal(t, "new", set.Keys[0].Kid)
+		assert.Equal(t, "old", set.Keys[1].Kid)
+	})
+}
@@ -1,9 +1,11 @@
 package gincup
 
 import (
+	"context"
+	"crypto"
 	"errors"
 	"net/http"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,18 +15,53 @@ import (
 var (
 	ErrJWTInvalidToken = errors.New("invalid token")
 	ErrJWTTokenExpired = errors.New("token expired")
+
+	ErrJWTInvalidAuthorizationHeader = errors.New("invalid Authorization header format")
+
+	ErrJWTSigningKeyMissing = errors.New("signing key is missing")
+	ErrJWTUnsupportedKey    = errors.New("unsupported key type")
+)
+
+const (
+	subjectContextKey     = "subject"
+	claimsContextKey      = "claims"
+	typedClaimsContextKey = "typed_claims"
 )
 
 type JWT struct {
-	secret         []byte
+	mu             sync.RWMutex
+	activeKey      *jwtKey
+	keys           map[string]*jwtKey
+	remote         *RemoteJWKS
 	expireDuration time.Duration
+
+	issuer           string
+	audience         []string
+	leeway           time.Duration
+	requireNotBefore bool
+	requireIssuedAt  bool
+	timeFunc         func() time.Time
+
+	revocations   RevocationStore
+	tokenVersions TokenVersionLookup
+
+	extractors []TokenExtractor
+	responder  ErrorResponder
+	realm      string
+
+	session *SlidingSession
+
+	algorithms []string
+	encryption *jweEncryption
 }
 
 // NewJWT creates a new JWT instance.
 //
+// The options configure the claims stamped on and required from tokens.
+//
 // If the secret is empty, panic.
 // If the expire duration is less than or equal to 0, panic.
-func NewJWT(secret string, expireDuration time.Duration) *JWT {
+func NewJWT(secret string, expireDuration time.Duration, opts ...JWTOption) *JWT {
 	if secret == "" {
 		panic("secret is required")
 	}
@@ -33,108 +70,315 @@ func NewJWT(secret string, expireDuration time.Duration) *JWT {
 		panic("expire duration must be greater than 0")
 	}
 
-	return &JWT{
-		secret:         []byte(secret),
-		expireDuration: expireDuration,
+	key, err := newJWTKey("", secret)
+	if err != nil {
+		panic(err.Error())
 	}
+
+	return newJWTWithKey(key, expireDuration, opts)
+}
+
+// NewJWTWithPrivateKey creates a new JWT instance that signs tokens with an
+// asymmetric private key.
+//
+// The key must be an *rsa.PrivateKey (RS256), an *ecdsa.PrivateKey (ES256,
+// ES384 or ES512 depending on the curve) or an ed25519.PrivateKey (EdDSA).
+// Tokens are verified with the public half of the key.
+//
+// If the key is not supported, panic.
+// If the expire duration is less than or equal to 0, panic.
+func NewJWTWithPrivateKey(key crypto.PrivateKey, expireDuration time.Duration, opts ...JWTOption) *JWT {
+	if expireDuration <= 0 {
+		panic("expire duration must be greater than 0")
+	}
+
+	method, verifyKey, err := signingMethodForPrivateKey(key)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return newJWTWithKey(&jwtKey{method: method, signKey: key, verifyKey: verifyKey}, expireDuration, opts)
+}
+
+// NewJWTVerifier creates a new JWT instance that can only verify tokens.
+//
+// The key must be an *rsa.PublicKey, an *ecdsa.PublicKey or an
+// ed25519.PublicKey. GenerateToken and GenerateTokenAndSetSubject
+// return ErrJWTSigningKeyMissing on a verifier.
+//
+// If the key is not supported, panic.
+func NewJWTVerifier(key crypto.PublicKey, opts ...JWTOption) *JWT {
+	method, err := signingMethodForPublicKey(key)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return newJWTWithKey(&jwtKey{method: method, verifyKey: key}, 0, opts)
 }
 
 // GenerateToken generates a JWT token.
 //
 // The token will expire after the expire duration.
 func (j *JWT) GenerateToken() (string, error) {
-	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"exp": jwt.NewNumericDate(time.Now().Add(j.expireDuration)),
-	}).SignedString(j.secret)
+	return j.signClaims(jwt.MapClaims{})
 }
 
 // GenerateTokenAndSetSubject generates a JWT token and sets the subject to the token.
 //
 // The token will expire after the expire duration.
 func (j *JWT) GenerateTokenAndSetSubject(sub string) (string, error) {
-	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	return j.signClaims(jwt.MapClaims{
 		"sub": sub,
-		"exp": jwt.NewNumericDate(time.Now().Add(j.expireDuration)),
-	}).SignedString(j.secret)
+	})
 }
 
-// validateToken validates a JWT token.
+// signClaims signs claims with the active signing key.
 //
-// If the token is invalid or expired, the function will return an error.
-func (j *JWT) validateToken(token string) error {
-	_, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		return j.secret, nil
-	})
+// The "exp", "iat", "nbf", "jti", "iss" and "aud" claims are stamped unless
+// the claims already have them, as is the "ver" claim of tokens with a
+// subject. With a sliding session, tokens with a subject also get an
+// "auth_time" claim.
+// The "kid" header is set if the key has a key id.
+// The signed token is encrypted if encryption is set with WithEncryption.
+func (j *JWT) signClaims(claims jwt.MapClaims) (string, error) {
+	key, err := j.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := j.now()
+	setDefaultClaim(claims, "exp", jwt.NewNumericDate(now.Add(j.expireDuration)))
+	setDefaultClaim(claims, "iat", jwt.NewNumericDate(now))
+	setDefaultClaim(claims, "nbf", jwt.NewNumericDate(now))
+	if _, ok := claims["jti"]; !ok {
+		jti, err := newTokenID()
+		if err != nil {
+			return "", err
+		}
+		claims["jti"] = jti
+	}
+	if sub, ok := claims["sub"].(string); ok && sub != "" && j.session != nil {
+		setDefaultClaim(claims, "auth_time", jwt.NewNumericDate(now))
+	}
+	if err := j.setTokenVersion(claims); err != nil {
+		return "", err
+	}
+	if j.issuer != "" {
+		setDefaultClaim(claims, "iss", j.issuer)
+	}
+	if len(j.audience) > 0 {
+		setDefaultClaim(claims, "aud", jwt.ClaimStrings(j.audience))
+	}
+
+	t := jwt.NewWithClaims(key.method, claims)
+	if key.id != "" {
+		t.Header["kid"] = key.id
+	}
+	token, err := t.SignedString(key.signKey)
+	if err != nil {
+		return "", err
+	}
+
+	if j.encryption != nil {
+		return j.encryption.encrypt(token)
+	}
+	return token, nil
+}
+
+// setDefaultClaim sets a claim unless it is already set.
+func setDefaultClaim(claims jwt.MapClaims, name string, value interface{}) {
+	if _, ok := claims[name]; !ok {
+		claims[name] = value
+	}
+}
+
+// keyFunc returns the key used to verify a token.
+//
+// The signing algorithm of the token must be allowed for the key,
+// which rejects "none" and algorithms of another key family.
+func (j *JWT) keyFunc(t *jwt.Token) (interface{}, error) {
+	key, err := j.verificationKey(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Method == nil || !j.allowsAlgorithm(key, t.Method.Alg()) {
+		return nil, ErrJWTAlgorithmNotAllowed
+	}
+	return key.verifyKey, nil
+}
+
+// parseToken validates a JWT token and returns its claims.
+//
+// Numbers in the claims are decoded as json.Number.
+// The issuer, audience, not before and issued at claims are checked
+// according to the options of the JWT instance, and revoked or outdated
+// tokens are rejected.
+//
+// If the token is invalid, expired or revoked, the function will return an error.
+func (j *JWT) parseToken(ctx context.Context, token string) (jwt.MapClaims, error) {
+	claims, err := j.verifyToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := j.checkRevocation(ctx, claims); err != nil {
+		return nil, err
+	}
+	if err := j.checkTokenVersion(ctx, claims); err != nil {
+		return nil, err
+	}
+	if err := j.checkSessionLifetime(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifyToken verifies the signature and the claims of a JWT token.
+//
+// If encryption is set, the token is decrypted first.
+func (j *JWT) verifyToken(token string) (jwt.MapClaims, error) {
+	if j.encryption != nil {
+		decrypted, err := j.encryption.decrypt(token)
+		if err != nil {
+			return nil, err
+		}
+		token = decrypted
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, j.keyFunc, j.parserOptions()...)
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
-			return ErrJWTTokenExpired
+			return nil, ErrJWTTokenExpired
 		}
-		return ErrJWTInvalidToken
+		return nil, ErrJWTInvalidToken
 	}
 
-	return nil
+	if err := j.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ParseToken validates a JWT token outside of a gin request and returns
+// its claims, with the same checks as the middlewares.
+//
+// If the token is invalid, expired or revoked, the function will return an error.
+func (j *JWT) ParseToken(ctx context.Context, token string) (jwt.MapClaims, error) {
+	return j.parseToken(ctx, token)
+}
+
+// validateToken validates a JWT token.
+//
+// If the token is invalid or expired, the function will return an error.
+func (j *JWT) validateToken(token string) error {
+	_, err := j.parseToken(context.Background(), token)
+	return err
 }
 
 // validateTokenAndGetSubject validates a JWT token and returns the subject.
 //
 // If the token is invalid or expired, the function will return an error.
 func (j *JWT) validateTokenAndGetSubject(token string) (string, error) {
-	t, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		return j.secret, nil
-	})
+	claims, err := j.parseToken(context.Background(), token)
 	if err != nil {
-		if errors.Is(err, jwt.ErrTokenExpired) {
-			return "", ErrJWTTokenExpired
-		}
-		return "", ErrJWTInvalidToken
+		return "", err
 	}
 
-	claims, ok := t.Claims.(jwt.MapClaims)
-	if !ok {
+	// get subject
+	sub, err := claims.GetSubject()
+	if err != nil {
 		return "", ErrJWTInvalidToken
 	}
+	return sub, nil
+}
 
-	// get subject
-	return claims.GetSubject()
+// extractToken extracts the token with the first extractor that finds one.
+//
+// If no extractor finds a token, the error of the first extractor is returned.
+func (j *JWT) extractToken(c *gin.Context) (string, error) {
+	extractors := j.extractors
+	if len(extractors) == 0 {
+		extractors = defaultTokenExtractors
+	}
+
+	var notFound error
+	for _, extractor := range extractors {
+		token, err := extractor.ExtractToken(c)
+		if err == nil {
+			return token, nil
+		}
+		if !errors.Is(err, ErrJWTTokenNotFound) {
+			return "", err
+		}
+		if notFound == nil {
+			notFound = err
+		}
+	}
+
+	return "", notFound
+}
+
+// authenticate extracts the token of the request, validates it and
+// returns its claims.
+func (j *JWT) authenticate(c *gin.Context) (jwt.MapClaims, error) {
+	token, err := j.extractToken(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return j.parseBearerToken(c.Request.Context(), token)
+}
+
+// parseBearerToken validates a bearer token and returns its claims.
+//
+// Tokens with a "cnf" claim are bound to a key and rejected; DPoP-bound
+// tokens are only accepted with a proof by DPoPMiddleware.
+func (j *JWT) parseBearerToken(ctx context.Context, token string) (jwt.MapClaims, error) {
+	claims, err := j.parseToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// any confirmation claim binds the token to a key, even a malformed one
+	if _, ok := claims["cnf"]; ok {
+		return nil, ErrDPoPBoundToken
+	}
+	return claims, nil
+}
+
+// abort aborts the request with a 401 Unauthorized status.
+//
+// The WWW-Authenticate header describes why the request was rejected.
+func (j *JWT) abort(c *gin.Context, err error) {
+	j.setChallenge(c, http.StatusUnauthorized, err)
+	j.respondError(c, http.StatusUnauthorized, err)
+}
+
+// setClaims sets the subject and the claims of a validated token to the context.
+func setClaims(c *gin.Context, claims jwt.MapClaims) error {
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return ErrJWTInvalidToken
+	}
+
+	c.Set(subjectContextKey, subject)
+	c.Set(claimsContextKey, claims)
+	return nil
 }
 
 // Middleware is a middleware that validates a JWT token.
 //
-// The Authorization header must be in the format "Bearer <token>".
+// By default, the token is read from the Authorization header in the format
+// "Bearer <token>". Other locations are set with WithTokenExtractors.
 //
 // If the token is invalid or expired, the middleware will return a 401 Unauthorized status.
 func (j *JWT) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid Authorization header format"})
-			c.Abort()
-			return
-		}
-
-		// trim Bearer prefix
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		// no prefix was trimmed
-		if token == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid Authorization header format"})
-			c.Abort()
-			return
-		}
-
 		// validate token
-		err := j.validateToken(token)
-		if err != nil {
-			var message string
-			switch {
-			case errors.Is(err, ErrJWTTokenExpired):
-				message = "token expired"
-			case errors.Is(err, ErrJWTInvalidToken):
-				message = "invalid token"
-			default:
-				message = "unauthorized"
-			}
-			c.JSON(http.StatusUnauthorized, gin.H{"message": message})
-			c.Abort()
+		if _, err := j.authenticate(c); err != nil {
+			j.abort(c, err)
 			return
 		}
 
@@ -145,48 +389,67 @@ func (j *JWT) Middleware() gin.HandlerFunc {
 // MiddlewareWithSubject is a middleware that validates a JWT token and
 // sets the subject to the context.
 //
-// The Authorization header must be in the format "Bearer <token>".
+// By default, the token is read from the Authorization header in the format
+// "Bearer <token>". Other locations are set with WithTokenExtractors.
 //
 // If the token is invalid or expired, the middleware will return a 401 Unauthorized status.
 //
-// If the token is valid, the subject will be set to the context.
+// If the token is valid, the subject and the claims will be set to the context.
+//
+// If a sliding session is set with WithSlidingSession, tokens close to
+// their expiration are renewed.
 func (j *JWT) MiddlewareWithSubject() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid Authorization header format"})
-			c.Abort()
+		// validate token and get claims
+		claims, err := j.authenticate(c)
+		if err != nil {
+			j.abort(c, err)
 			return
 		}
 
-		// trim Bearer prefix
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		// no prefix was trimmed
-		if token == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid Authorization header format"})
-			c.Abort()
+		// set subject and claims to context
+		if err := setClaims(c, claims); err != nil {
+			j.abort(c, err)
 			return
 		}
+		j.renewSession(c, claims)
+		c.Next()
+	}
+}
 
-		// validate token and get subject
-		subject, err := j.validateTokenAndGetSubject(token)
+// OptionalMiddleware is a middleware that validates a JWT token if the
+// request has one.
+//
+// Requests without a token are passed through unchanged.
+//
+// If the token is malformed, invalid or expired, the middleware will return a 401 Unauthorized status.
+//
+// If the token is valid, the subject and the claims will be set to the context
+// as with MiddlewareWithSubject.
+func (j *JWT) OptionalMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := j.extractToken(c)
 		if err != nil {
-			var message string
-			switch {
-			case errors.Is(err, ErrJWTTokenExpired):
-				message = "token expired"
-			case errors.Is(err, ErrJWTInvalidToken):
-				message = "invalid token"
-			default:
-				message = "unauthorized"
+			// anonymous request
+			if errors.Is(err, ErrJWTTokenNotFound) {
+				c.Next()
+				return
 			}
-			c.JSON(http.StatusUnauthorized, gin.H{"message": message})
-			c.Abort()
+			j.abort(c, err)
 			return
 		}
 
-		// set subject to context
-		c.Set("subject", subject)
+		claims, err := j.parseBearerToken(c.Request.Context(), token)
+		if err != nil {
+			j.abort(c, err)
+			return
+		}
+
+		if err := setClaims(c, claims); err != nil {
+			j.abort(c, err)
+			return
+		}
+		j.renewSession(c, claims)
 		c.Next()
 	}
 }
@@ -195,7 +458,7 @@ func (j *JWT) MiddlewareWithSubject() gin.HandlerFunc {
 //
 // If the subject is not set, the function will return an empty string.
 func (j *JWT) GetSubjectFromGinContext(c *gin.Context) string {
-	subject, ok := c.Get("subject")
+	subject, ok := c.Get(subjectContextKey)
 	if !ok {
 		return ""
 	}
@@ -0,0 +1,135 @@
+package gincup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrJWTInvalidClaims = errors.New("claims must be encoded as a JSON object")
+)
+
+// GenerateTokenWithClaims generates a JWT token with custom claims.
+//
+// The claims are encoded as JSON, so T is usually a struct with json tags,
+// which may embed jwt.RegisteredClaims for the registered claims.
+//
+// If the claims have no "exp", the token will expire after the expire duration.
+// Other registered claims missing from the claims are stamped as by GenerateToken.
+func GenerateTokenWithClaims[T any](j *JWT, claims T) (string, error) {
+	m, err := toMapClaims(claims)
+	if err != nil {
+		return "", err
+	}
+
+	return j.signClaims(m)
+}
+
+// MiddlewareWithClaims is a middleware that validates a JWT token and
+// sets the subject and the claims decoded into T to the context.
+//
+// The claims can be read with GetClaimsFromGinContext.
+//
+// If the token is invalid, expired or its claims cannot be decoded into T,
+// the middleware will return a 401 Unauthorized status.
+func MiddlewareWithClaims[T any](j *JWT) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := j.authenticate(c)
+		if err != nil {
+			j.abort(c, err)
+			return
+		}
+
+		typed, err := fromMapClaims[T](claims)
+		if err != nil {
+			j.abort(c, ErrJWTInvalidToken)
+			return
+		}
+
+		if err := setClaims(c, claims); err != nil {
+			j.abort(c, err)
+			return
+		}
+		c.Set(typedClaimsContextKey, typed)
+		c.Next()
+	}
+}
+
+// GetClaimsFromGinContext gets the claims of the validated token from the
+// gin context, decoded into T.
+//
+// The claims are set by MiddlewareWithClaims and MiddlewareWithSubject.
+// If the claims are not set or cannot be decoded into T, the function
+// will return false.
+func GetClaimsFromGinContext[T any](c *gin.Context) (*T, bool) {
+	if v, ok := c.Get(typedClaimsContextKey); ok {
+		if typed, ok := v.(*T); ok {
+			return typed, true
+		}
+	}
+
+	claims, ok := claimsFromGinContext(c)
+	if !ok {
+		return nil, false
+	}
+
+	typed, err := fromMapClaims[T](claims)
+	if err != nil {
+		return nil, false
+	}
+	return typed, true
+}
+
+// numericDateClaim returns a claim that is a NumericDate.
+func numericDateClaim(claims jwt.MapClaims, name string) (time.Time, bool) {
+	var seconds float64
+	switch v := claims[name].(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		seconds = f
+	case float64:
+		seconds = v
+	default:
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// toMapClaims encodes claims to JSON and decodes them into jwt.MapClaims.
+func toMapClaims(claims any) (jwt.MapClaims, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	var m jwt.MapClaims
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&m); err != nil || m == nil {
+		return nil, ErrJWTInvalidClaims
+	}
+	return m, nil
+}
+
+// fromMapClaims decodes jwt.MapClaims into T.
+func fromMapClaims[T any](claims jwt.MapClaims) (*T, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := new(T)
+	if err := json.Unmarshal(data, typed); err != nil {
+		return nil, err
+	}
+	return typed, nil
+}
@@ -0,0 +1,126 @@
+package gincup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type testClaims struct {
+	Roles    []string `json:"roles"`
+	TenantID int64    `json:"tenant_id"`
+	Email    string   `json:"email"`
+	jwt.RegisteredClaims
+}
+
+func TestGenerateTokenWithClaims(t *testing.T) {
+	j := NewJWT("secret", 1*time.Hour)
+
+	t.Run("custom claims", func(t *testing.T) {
+		token, err := GenerateTokenWithClaims(j, testClaims{
+			Roles:            []string{"admin"},
+			TenantID:         9007199254740993,
+			Email:            "test@example.com",
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "test123"},
+		})
+		assert.NoError(t, err)
+
+		claims, err := j.parseToken(context.Background(), token)
+		assert.NoError(t, err)
+		assert.Equal(t, "test123", claims["sub"])
+		assert.Contains(t, claims, "exp")
+
+		typed, err := fromMapClaims[testClaims](claims)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"admin"}, typed.Roles)
+		assert.Equal(t, int64(9007199254740993), typed.TenantID)
+	})
+
+	t.Run("explicit expiration", func(t *testing.T) {
+		token, err := GenerateTokenWithClaims(j, jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		})
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, j.validateToken(token), ErrJWTTokenExpired)
+	})
+
+	t.Run("claims must be an object", func(t *testing.T) {
+		_, err := GenerateTokenWithClaims(j, []string{"admin"})
+		assert.ErrorIs(t, err, ErrJWTInvalidClaims)
+	})
+}
+
+func TestMiddlewareWithClaims(t *testing.T) {
+	j := NewJWT("secret", 1*time.Hour)
+
+	router := gin.New()
+	router.GET("/typed", MiddlewareWithClaims[testClaims](j), func(c *gin.Context) {
+		claims, ok := GetClaimsFromGinContext[testClaims](c)
+		assert.True(t, ok)
+		c.JSON(http.StatusOK, gin.H{
+			"subject": j.GetSubjectFromGinContext(c),
+			"email":   claims.Email,
+			"tenant":  claims.TenantID,
+		})
+	})
+	router.GET("/subject", j.MiddlewareWithSubject(), func(c *gin.Context) {
+		claims, ok := GetClaimsFromGinContext[testClaims](c)
+		assert.True(t, ok)
+		c.JSON(http.StatusOK, gin.H{"roles": claims.Roles})
+	})
+
+	token, err := GenerateTokenWithClaims(j, testClaims{
+		Roles:            []string{"admin", "user"},
+		TenantID:         42,
+		Email:            "test@example.com",
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "test123"},
+	})
+	assert.NoError(t, err)
+
+	t.Run("typed claims", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/typed", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subject":"test123","email":"test@example.com","tenant":42}`, w.Body.String())
+	})
+
+	t.Run("claims from MiddlewareWithSubject", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/subject", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"roles":["admin","user"]}`, w.Body.String())
+	})
+
+	t.Run("claims do not match", func(t *testing.T) {
+		token, err := GenerateTokenWithClaims(j, map[string]any{"tenant_id": "not a number"})
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/typed", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"invalid token"}`, w.Body.String())
+	})
+
+	t.Run("missing claims", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		_, ok := GetClaimsFromGinContext[testClaims](c)
+		assert.False(t, ok)
+	})
+}
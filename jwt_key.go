@@ -0,0 +1,285 @@
+package gincup
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrJWTKeyIDRequired  = errors.New("key id is required")
+	ErrJWTKeyIDExists    = errors.New("key id already exists")
+	ErrJWTKeyNotFound    = errors.New("key not found")
+	ErrJWTActiveKeyInUse = errors.New("active signing key cannot be removed")
+
+	ErrJWTAlgorithmNotAllowed = errors.New("signing algorithm not allowed")
+)
+
+// jwtKey is a key of the JWT keyring.
+//
+// signKey is nil for keys that can only verify tokens.
+type jwtKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// newJWTKey creates a keyring entry from a key.
+//
+// A string or []byte is used as an HMAC secret (HS256).
+// A private key or a crypto.Signer signs tokens and verifies them with its
+// public half.
+// A public key can only verify tokens.
+func newJWTKey(id string, key interface{}) (*jwtKey, error) {
+	switch k := key.(type) {
+	case string:
+		return newJWTKey(id, []byte(k))
+	case []byte:
+		if len(k) == 0 {
+			return nil, ErrJWTUnsupportedKey
+		}
+		return &jwtKey{id: id, method: jwt.SigningMethodHS256, signKey: k, verifyKey: k}, nil
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		method, err := signingMethodForPublicKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return &jwtKey{id: id, method: method, verifyKey: k}, nil
+	default:
+		method, verifyKey, err := signingMethodForPrivateKey(k)
+		if err == nil {
+			return &jwtKey{id: id, method: method, signKey: k, verifyKey: verifyKey}, nil
+		}
+		if signer, ok := k.(crypto.Signer); ok {
+			return newSignerKey(id, signer)
+		}
+		return nil, err
+	}
+}
+
+// signingMethodForPrivateKey returns the signing method and the public key
+// matching an asymmetric private key.
+func signingMethodForPrivateKey(key crypto.PrivateKey) (jwt.SigningMethod, crypto.PublicKey, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, &k.PublicKey, nil
+	case *ecdsa.PrivateKey:
+		method, err := signingMethodForPublicKey(&k.PublicKey)
+		return method, &k.PublicKey, err
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, k.Public(), nil
+	default:
+		return nil, nil, ErrJWTUnsupportedKey
+	}
+}
+
+// signingMethodForPublicKey returns the signing method matching an
+// asymmetric public key.
+func signingMethodForPublicKey(key crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, ErrJWTUnsupportedKey
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, ErrJWTUnsupportedKey
+	}
+}
+
+// compatibleAlgorithms returns the algorithms whose signatures can be
+// verified with the key.
+func compatibleAlgorithms(verifyKey interface{}) []string {
+	switch k := verifyKey.(type) {
+	case []byte:
+		return []string{"HS256", "HS384", "HS512"}
+	case *rsa.PublicKey:
+		return []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	case *ecdsa.PublicKey:
+		method, err := signingMethodForPublicKey(k)
+		if err != nil {
+			return nil
+		}
+		return []string{method.Alg()}
+	case ed25519.PublicKey:
+		return []string{"EdDSA"}
+	default:
+		return nil
+	}
+}
+
+// allowsAlgorithm reports whether a token signed with the algorithm may be
+// verified with the key.
+//
+// The algorithm must belong to the family of the key. Without an explicit
+// allowlist, it must also be the signing algorithm of the key.
+func (j *JWT) allowsAlgorithm(key *jwtKey, alg string) bool {
+	if !slices.Contains(compatibleAlgorithms(key.verifyKey), alg) {
+		return false
+	}
+
+	if len(j.algorithms) > 0 {
+		return slices.Contains(j.algorithms, alg)
+	}
+	return alg == key.method.Alg()
+}
+
+// newJWTWithKey creates a JWT instance whose keyring holds a single key
+// without a key id.
+func newJWTWithKey(key *jwtKey, expireDuration time.Duration, opts []JWTOption) *JWT {
+	j := &JWT{
+		keys:           map[string]*jwtKey{key.id: key},
+		expireDuration: expireDuration,
+	}
+	if key.signKey != nil {
+		j.activeKey = key
+	}
+	j.applyOptions(opts)
+	return j
+}
+
+// RotateKey makes key the active signing key.
+//
+// Tokens generated from now on carry kid in their "kid" header. The previous
+// signing key stays in the keyring, so tokens it has signed remain valid until
+// the key is removed with RemoveKey.
+//
+// The key is a string or []byte HMAC secret, an *rsa.PrivateKey,
+// *ecdsa.PrivateKey or ed25519.PrivateKey, or a crypto.Signer.
+func (j *JWT) RotateKey(kid string, key interface{}) error {
+	if kid == "" {
+		return ErrJWTKeyIDRequired
+	}
+
+	k, err := newJWTKey(kid, key)
+	if err != nil {
+		return err
+	}
+	if k.signKey == nil {
+		return ErrJWTSigningKeyMissing
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, ok := j.keys[kid]; ok {
+		return ErrJWTKeyIDExists
+	}
+	j.keys[kid] = k
+	j.activeKey = k
+	return nil
+}
+
+// AddVerificationKey adds a key that is only used to verify tokens whose
+// "kid" header is kid.
+//
+// The key is a string or []byte HMAC secret, or an RSA, ECDSA or Ed25519
+// public key. A private key is accepted too, but only its public half is used.
+func (j *JWT) AddVerificationKey(kid string, key interface{}) error {
+	if kid == "" {
+		return ErrJWTKeyIDRequired
+	}
+
+	k, err := newJWTKey(kid, key)
+	if err != nil {
+		return err
+	}
+	k.signKey = nil
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, ok := j.keys[kid]; ok {
+		return ErrJWTKeyIDExists
+	}
+	j.keys[kid] = k
+	return nil
+}
+
+// RemoveKey removes a key from the keyring.
+//
+// Tokens signed by the key are rejected afterwards.
+// The active signing key cannot be removed.
+func (j *JWT) RemoveKey(kid string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	k, ok := j.keys[kid]
+	if !ok {
+		return ErrJWTKeyNotFound
+	}
+	if k == j.activeKey {
+		return ErrJWTActiveKeyInUse
+	}
+	delete(j.keys, kid)
+	return nil
+}
+
+// ActiveKeyID returns the key id of the active signing key.
+//
+// It is empty if the signing key has no key id.
+func (j *JWT) ActiveKeyID() string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if j.activeKey == nil {
+		return ""
+	}
+	return j.activeKey.id
+}
+
+// signingKey returns the active signing key.
+func (j *JWT) signingKey() (*jwtKey, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if j.activeKey == nil {
+		return nil, ErrJWTSigningKeyMissing
+	}
+	return j.activeKey, nil
+}
+
+// verificationKey returns the key matching the "kid" header of a token.
+//
+// Tokens without a "kid" header are verified by the key without a key id.
+// If the keys come from a remote JSON Web Key Set, the key is looked up there.
+func (j *JWT) verificationKey(t *jwt.Token) (*jwtKey, error) {
+	kid := ""
+	if v, ok := t.Header["kid"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return nil, ErrJWTKeyNotFound
+		}
+		kid = s
+	}
+
+	if j.remote != nil {
+		return j.remote.lookupKey(kid)
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	k, ok := j.keys[kid]
+	if !ok {
+		return nil, ErrJWTKeyNotFound
+	}
+	return k, nil
+}
@@ -0,0 +1,188 @@
+package gincup
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTKeyring(t *testing.T) {
+	t.Run("rotate keeps old tokens valid", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour)
+
+		oldToken, err := j.GenerateTokenAndSetSubject("old")
+		assert.NoError(t, err)
+
+		assert.NoError(t, j.RotateKey("k1", "secret-1"))
+		assert.Equal(t, "k1", j.ActiveKeyID())
+
+		newToken, err := j.GenerateTokenAndSetSubject("new")
+		assert.NoError(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+		assert.NoError(t, err)
+		assert.Equal(t, "k1", parsed.Header["kid"])
+
+		sub, err := j.validateTokenAndGetSubject(oldToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "old", sub)
+
+		sub, err = j.validateTokenAndGetSubject(newToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "new", sub)
+	})
+
+	t.Run("removed key rejects tokens", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour)
+		assert.NoError(t, j.RotateKey("k1", "secret-1"))
+
+		token, err := j.GenerateToken()
+		assert.NoError(t, err)
+
+		assert.NoError(t, j.RotateKey("k2", "secret-2"))
+		assert.NoError(t, j.validateToken(token))
+
+		assert.NoError(t, j.RemoveKey("k1"))
+		assert.ErrorIs(t, j.validateToken(token), ErrJWTInvalidToken)
+	})
+
+	t.Run("unknown kid", func(t *testing.T) {
+		issuer := NewJWT("secret", 1*time.Hour)
+		assert.NoError(t, issuer.RotateKey("k1", "secret"))
+
+		token, err := issuer.GenerateToken()
+		assert.NoError(t, err)
+
+		verifier := NewJWT("secret", 1*time.Hour)
+		assert.ErrorIs(t, verifier.validateToken(token), ErrJWTInvalidToken)
+	})
+
+	t.Run("verification key", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+
+		issuer := NewJWTWithPrivateKey(key, 1*time.Hour)
+		assert.NoError(t, issuer.RotateKey("ec-1", key))
+
+		token, err := issuer.GenerateToken()
+		assert.NoError(t, err)
+
+		verifier := NewJWT("secret", 1*time.Hour)
+		assert.NoError(t, verifier.AddVerificationKey("ec-1", key.Public()))
+		assert.NoError(t, verifier.validateToken(token))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour)
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, j.RotateKey("", "secret-1"), ErrJWTKeyIDRequired)
+		assert.ErrorIs(t, j.RotateKey("k1", key.Public()), ErrJWTSigningKeyMissing)
+		assert.ErrorIs(t, j.RotateKey("k1", 123), ErrJWTUnsupportedKey)
+		assert.NoError(t, j.RotateKey("k1", "secret-1"))
+		assert.ErrorIs(t, j.RotateKey("k1", "secret-2"), ErrJWTKeyIDExists)
+		assert.ErrorIs(t, j.AddVerificationKey("k1", "secret-2"), ErrJWTKeyIDExists)
+		assert.ErrorIs(t, j.RemoveKey("k1"), ErrJWTActiveKeyInUse)
+		assert.ErrorIs(t, j.RemoveKey("k2"), ErrJWTKeyNotFound)
+	})
+
+	t.Run("rotate at runtime", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour)
+
+		router := gin.New()
+		router.Use(j.Middleware())
+		router.GET("/test", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		assert.NoError(t, j.RotateKey("k1", "secret-1"))
+		token, err := j.GenerateToken()
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestJWTAlgorithmAllowlist(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	claims := jwt.MapClaims{"exp": jwt.NewNumericDate(time.Now().Add(time.Hour))}
+
+	t.Run("alg none", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, NewJWT("secret", time.Hour).validateToken(token), ErrJWTInvalidToken)
+		assert.ErrorIs(t, NewJWT("secret", time.Hour, WithAllowedAlgorithms("none", "HS256")).validateToken(token), ErrJWTInvalidToken)
+		assert.ErrorIs(t, NewJWTVerifier(rsaKey.Public()).validateToken(token), ErrJWTInvalidToken)
+	})
+
+	t.Run("hmac signed with public key", func(t *testing.T) {
+		der, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+		assert.NoError(t, err)
+		pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(pemKey)
+		assert.NoError(t, err)
+
+		verifier := NewJWTVerifier(rsaKey.Public(), WithAllowedAlgorithms("HS256", "RS256"))
+		assert.ErrorIs(t, verifier.validateToken(token), ErrJWTInvalidToken)
+	})
+
+	t.Run("mismatched key family", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(ecKey)
+		assert.NoError(t, err)
+
+		verifier := NewJWTVerifier(edPub, WithAllowedAlgorithms("ES256", "EdDSA"))
+		assert.ErrorIs(t, verifier.validateToken(token), ErrJWTInvalidToken)
+	})
+
+	t.Run("only the key algorithm by default", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS384, claims).SignedString(rsaKey)
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, NewJWTVerifier(rsaKey.Public()).validateToken(token), ErrJWTInvalidToken)
+		assert.NoError(t, NewJWTVerifier(rsaKey.Public(), WithAllowedAlgorithms("RS256", "RS384")).validateToken(token))
+		assert.ErrorIs(t, NewJWTVerifier(rsaKey.Public(), WithAllowedAlgorithms("PS256")).validateToken(token), ErrJWTInvalidToken)
+
+		token, err = jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, NewJWT("secret", time.Hour).validateToken(token), ErrJWTInvalidToken)
+		assert.NoError(t, NewJWT("secret", time.Hour, WithAllowedAlgorithms("HS512")).validateToken(token))
+	})
+
+	t.Run("jwk with mismatched alg", func(t *testing.T) {
+		jwk, err := NewJSONWebKey("rsa", rsaKey.Public())
+		assert.NoError(t, err)
+
+		jwk.Alg = "HS256"
+		assert.Empty(t, JSONWebKeySet{Keys: []JSONWebKey{jwk}}.verificationKeys())
+
+		jwk.Alg = "PS256"
+		assert.Len(t, JSONWebKeySet{Keys: []JSONWebKey{jwk}}.verificationKeys(), 1)
+	})
+}
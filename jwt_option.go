@@ -0,0 +1,227 @@
+package gincup
+
+import (
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTOption configures a JWT instance.
+type JWTOption func(*JWT)
+
+// WithIssuer sets the issuer.
+//
+// The issuer is stamped as the "iss" claim of generated tokens, and tokens
+// with another issuer are rejected.
+func WithIssuer(iss string) JWTOption {
+	return func(j *JWT) {
+		j.issuer = iss
+	}
+}
+
+// WithAudience sets the accepted audiences.
+//
+// The audiences are stamped as the "aud" claim of generated tokens, and
+// tokens are rejected unless their "aud" claim has one of them.
+func WithAudience(aud ...string) JWTOption {
+	return func(j *JWT) {
+		j.audience = aud
+	}
+}
+
+// WithLeeway sets the clock skew allowed when checking the "exp", "nbf"
+// and "iat" claims.
+//
+// If the leeway is less than 0, panic.
+func WithLeeway(leeway time.Duration) JWTOption {
+	if leeway < 0 {
+		panic("leeway must be greater than or equal to 0")
+	}
+
+	return func(j *JWT) {
+		j.leeway = leeway
+	}
+}
+
+// WithRequiredNotBefore rejects tokens without a "nbf" claim.
+func WithRequiredNotBefore() JWTOption {
+	return func(j *JWT) {
+		j.requireNotBefore = true
+	}
+}
+
+// WithRequiredIssuedAt rejects tokens without a "iat" claim, or whose
+// "iat" claim is in the future.
+func WithRequiredIssuedAt() JWTOption {
+	return func(j *JWT) {
+		j.requireIssuedAt = true
+	}
+}
+
+// WithRevocationStore sets the store consulted to reject revoked tokens.
+//
+// Tokens are revoked with Revoke.
+func WithRevocationStore(store RevocationStore) JWTOption {
+	return func(j *JWT) {
+		j.revocations = store
+	}
+}
+
+// WithTokenVersion sets the lookup of the current token version of subjects.
+//
+// Tokens generated for a subject carry its current version in the "ver"
+// claim, and tokens whose version differs from the current one are rejected.
+// Incrementing the version of a subject, for example when its password
+// changes, invalidates every token previously issued for it.
+func WithTokenVersion(lookup TokenVersionLookup) JWTOption {
+	return func(j *JWT) {
+		j.tokenVersions = lookup
+	}
+}
+
+// WithTokenExtractors sets where tokens are read from.
+//
+// The extractors are tried in order and the first token found is used.
+// The default is HeaderExtractor("Authorization", "Bearer").
+func WithTokenExtractors(extractors ...TokenExtractor) JWTOption {
+	return func(j *JWT) {
+		j.extractors = extractors
+	}
+}
+
+// WithErrorResponder sets the responder of rejected requests.
+//
+// The default is DefaultErrorResponder.
+func WithErrorResponder(responder ErrorResponder) JWTOption {
+	return func(j *JWT) {
+		j.responder = responder
+	}
+}
+
+// WithRealm sets the realm of the WWW-Authenticate challenges sent with
+// rejected requests.
+func WithRealm(realm string) JWTOption {
+	return func(j *JWT) {
+		j.realm = realm
+	}
+}
+
+// WithSlidingSession enables the renewal of tokens close to their expiration
+// by MiddlewareWithSubject and OptionalMiddleware.
+//
+// If the window or the maximum lifetime is less than or equal to 0, panic.
+func WithSlidingSession(session SlidingSession) JWTOption {
+	if session.Window <= 0 {
+		panic("sliding session window must be greater than 0")
+	}
+
+	if session.MaxLifetime <= 0 {
+		panic("sliding session max lifetime must be greater than 0")
+	}
+
+	if session.Header == "" {
+		session.Header = "X-Renewed-Token"
+	}
+
+	return func(j *JWT) {
+		j.session = &session
+	}
+}
+
+// WithAllowedAlgorithms sets the signing algorithms accepted by the verifier,
+// for example "RS256" and "PS256".
+//
+// An algorithm is only accepted for keys of its family: HMAC secrets for
+// HS256, HS384 and HS512, RSA keys for RS* and PS*, ECDSA keys for the ES*
+// algorithm of their curve and Ed25519 keys for EdDSA. "none" is never accepted.
+//
+// By default, a token is only accepted if it is signed with the algorithm
+// of its key.
+func WithAllowedAlgorithms(algs ...string) JWTOption {
+	return func(j *JWT) {
+		j.algorithms = algs
+	}
+}
+
+// WithTimeFunc sets the function that returns the current time, used to
+// issue tokens and to check their expiration. The default is time.Now.
+//
+// It lets tests control the clock, as the gincuptest package does.
+// The memory stores keep using the real time to remove expired entries.
+func WithTimeFunc(now func() time.Time) JWTOption {
+	return func(j *JWT) {
+		j.timeFunc = now
+	}
+}
+
+// applyOptions applies the options to the JWT instance.
+func (j *JWT) applyOptions(opts []JWTOption) {
+	for _, opt := range opts {
+		opt(j)
+	}
+}
+
+// parserOptions returns the options used to parse tokens.
+func (j *JWT) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(j.validMethods()),
+		jwt.WithJSONNumber(),
+		jwt.WithLeeway(j.leeway),
+	}
+	if j.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(j.issuer))
+	}
+	if j.requireIssuedAt {
+		opts = append(opts, jwt.WithIssuedAt())
+	}
+	if j.timeFunc != nil {
+		opts = append(opts, jwt.WithTimeFunc(j.timeFunc))
+	}
+	return opts
+}
+
+// now returns the current time of the JWT instance.
+func (j *JWT) now() time.Time {
+	if j.timeFunc != nil {
+		return j.timeFunc()
+	}
+	return time.Now()
+}
+
+// validMethods returns the algorithms accepted by the parser.
+//
+// The keys may narrow them further.
+func (j *JWT) validMethods() []string {
+	if len(j.algorithms) > 0 {
+		return slices.DeleteFunc(slices.Clone(j.algorithms), func(alg string) bool { return alg == "none" })
+	}
+	return []string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+}
+
+// validateClaims checks the claims that the parser does not check.
+func (j *JWT) validateClaims(claims jwt.MapClaims) error {
+	if j.requireNotBefore {
+		if _, ok := claims["nbf"]; !ok {
+			return ErrJWTInvalidToken
+		}
+	}
+
+	if j.requireIssuedAt {
+		if _, ok := claims["iat"]; !ok {
+			return ErrJWTInvalidToken
+		}
+	}
+
+	if len(j.audience) > 0 {
+		aud, err := claims.GetAudience()
+		if err != nil {
+			return ErrJWTInvalidToken
+		}
+		if !slices.ContainsFunc(aud, func(a string) bool { return slices.Contains(j.audience, a) }) {
+			return ErrJWTInvalidToken
+		}
+	}
+
+	return nil
+}
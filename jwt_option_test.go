@@ -0,0 +1,109 @@
+package gincup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTOptions(t *testing.T) {
+	t.Run("stamped claims", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour, WithIssuer("auth"), WithAudience("api", "admin"))
+
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		claims, err := j.parseToken(context.Background(), token)
+		assert.NoError(t, err)
+		assert.Equal(t, "auth", claims["iss"])
+		assert.Equal(t, []interface{}{"api", "admin"}, claims["aud"])
+		assert.Contains(t, claims, "iat")
+		assert.Contains(t, claims, "nbf")
+	})
+
+	t.Run("issuer", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour, WithIssuer("auth"))
+		other := NewJWT("secret", 1*time.Hour, WithIssuer("other"))
+
+		token, err := other.GenerateToken()
+		assert.NoError(t, err)
+		assert.ErrorIs(t, j.validateToken(token), ErrJWTInvalidToken)
+
+		token, err = NewJWT("secret", 1*time.Hour).GenerateToken()
+		assert.NoError(t, err)
+		assert.ErrorIs(t, j.validateToken(token), ErrJWTInvalidToken)
+	})
+
+	t.Run("audience", func(t *testing.T) {
+		billing := NewJWT("secret", 1*time.Hour, WithAudience("billing", "api"))
+		orders := NewJWT("secret", 1*time.Hour, WithAudience("orders"))
+
+		token, err := NewJWT("secret", 1*time.Hour, WithAudience("api")).GenerateToken()
+		assert.NoError(t, err)
+		assert.NoError(t, billing.validateToken(token))
+		assert.ErrorIs(t, orders.validateToken(token), ErrJWTInvalidToken)
+
+		token, err = NewJWT("secret", 1*time.Hour).GenerateToken()
+		assert.NoError(t, err)
+		assert.ErrorIs(t, billing.validateToken(token), ErrJWTInvalidToken)
+	})
+
+	t.Run("required not before and issued at", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour, WithRequiredNotBefore(), WithRequiredIssuedAt())
+
+		token, err := j.GenerateToken()
+		assert.NoError(t, err)
+		assert.NoError(t, j.validateToken(token))
+
+		token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"exp": jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			"iat": jwt.NewNumericDate(time.Now()),
+		}).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		assert.ErrorIs(t, j.validateToken(token), ErrJWTInvalidToken)
+
+		token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"exp": jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			"nbf": jwt.NewNumericDate(time.Now()),
+		}).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		assert.ErrorIs(t, j.validateToken(token), ErrJWTInvalidToken)
+	})
+
+	t.Run("leeway", func(t *testing.T) {
+		token, err := GenerateTokenWithClaims(NewJWT("secret", 1*time.Hour), jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-10 * time.Second)),
+			NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		})
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, NewJWT("secret", 1*time.Hour).validateToken(token), ErrJWTTokenExpired)
+		assert.NoError(t, NewJWT("secret", 1*time.Hour, WithLeeway(time.Minute)).validateToken(token))
+
+		token, err = GenerateTokenWithClaims(NewJWT("secret", 1*time.Hour), jwt.RegisteredClaims{
+			NotBefore: jwt.NewNumericDate(time.Now().Add(10 * time.Second)),
+		})
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, NewJWT("secret", 1*time.Hour).validateToken(token), ErrJWTInvalidToken)
+		assert.NoError(t, NewJWT("secret", 1*time.Hour, WithLeeway(time.Minute)).validateToken(token))
+
+		assert.Panics(t, func() { WithLeeway(-time.Second) })
+	})
+
+	t.Run("time func", func(t *testing.T) {
+		now := time.Now()
+		j := NewJWT("secret", 1*time.Hour, WithTimeFunc(func() time.Time { return now }))
+
+		token, err := j.GenerateToken()
+		assert.NoError(t, err)
+		assert.NoError(t, j.validateToken(token))
+
+		now = now.Add(2 * time.Hour)
+		assert.ErrorIs(t, j.validateToken(token), ErrJWTTokenExpired)
+		assert.NoError(t, NewJWT("secret", 1*time.Hour).validateToken(token))
+	})
+}
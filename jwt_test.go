@@ -1,12 +1,20 @@
 package gincup
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,14 +42,15 @@ func TestJWT(t *testing.T) {
 	})
 
 	t.Run("expired token", func(t *testing.T) {
-		j := NewJWT("secret123", 1*time.Second)
+		now := time.Now()
+		j := NewJWT("secret123", 1*time.Second, WithTimeFunc(func() time.Time { return now }))
 
 		token, err := j.GenerateTokenAndSetSubject("123")
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		time.Sleep(2 * time.Second)
+		now = now.Add(2 * time.Second)
 
 		_, err = j.validateTokenAndGetSubject(token)
 		if err != ErrJWTTokenExpired {
@@ -173,6 +182,18 @@ func TestJWTValidateToken(t *testing.T) {
 		err := j.validateToken("invalid.token.here")
 		assert.ErrorIs(t, err, ErrJWTInvalidToken)
 	})
+
+	t.Run("parse token", func(t *testing.T) {
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		claims, err := j.ParseToken(context.Background(), token)
+		assert.NoError(t, err)
+		assert.Equal(t, "test123", claims["sub"])
+
+		_, err = j.ParseToken(context.Background(), "invalid.token.here")
+		assert.ErrorIs(t, err, ErrJWTInvalidToken)
+	})
 }
 
 func TestJWTMiddlewareWithSubject(t *testing.T) {
@@ -281,3 +302,157 @@ func TestJWTGetSubjectFromGinContext(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.JSONEq(t, `{"subject":"test123"}`, w.Body.String())
 }
+
+func TestJWTAsymmetricKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		method string
+	}{
+		{"rsa", rsaKey, "RS256"},
+		{"ecdsa", ecKey, "ES256"},
+		{"ed25519", edKey, "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := NewJWTWithPrivateKey(tt.key, 1*time.Hour)
+			verifier := NewJWTVerifier(tt.key.Public())
+
+			token, err := issuer.GenerateTokenAndSetSubject("test123")
+			assert.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.method, parsed.Method.Alg())
+
+			sub, err := verifier.validateTokenAndGetSubject(token)
+			assert.NoError(t, err)
+			assert.Equal(t, "test123", sub)
+
+			router := gin.New()
+			router.Use(verifier.MiddlewareWithSubject())
+			router.GET("/test", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"subject": verifier.GetSubjectFromGinContext(c)})
+			})
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"subject":"test123"}`, w.Body.String())
+		})
+	}
+
+	t.Run("verifier cannot sign", func(t *testing.T) {
+		verifier := NewJWTVerifier(rsaKey.Public())
+
+		_, err := verifier.GenerateToken()
+		assert.ErrorIs(t, err, ErrJWTSigningKeyMissing)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+
+		token, err := NewJWTWithPrivateKey(ecKey, 1*time.Hour).GenerateToken()
+		assert.NoError(t, err)
+
+		err = NewJWTVerifier(otherKey.Public()).validateToken(token)
+		assert.ErrorIs(t, err, ErrJWTInvalidToken)
+	})
+
+	t.Run("hmac token rejected by verifier", func(t *testing.T) {
+		token, err := NewJWT("secret", 1*time.Hour).GenerateToken()
+		assert.NoError(t, err)
+
+		err = NewJWTVerifier(rsaKey.Public()).validateToken(token)
+		assert.ErrorIs(t, err, ErrJWTInvalidToken)
+	})
+
+	t.Run("unsupported key", func(t *testing.T) {
+		assert.Panics(t, func() { NewJWTWithPrivateKey("secret", 1*time.Hour) })
+		assert.Panics(t, func() { NewJWTVerifier([]byte("secret")) })
+	})
+}
+
+func TestJWTOptionalMiddleware(t *testing.T) {
+	j := NewJWT("secret", 1*time.Hour)
+
+	router := gin.New()
+	router.Use(j.OptionalMiddleware())
+	router.GET("/test", func(c *gin.Context) {
+		_, authenticated := c.Get("claims")
+		c.JSON(http.StatusOK, gin.H{
+			"subject":       j.GetSubjectFromGinContext(c),
+			"authenticated": authenticated,
+		})
+	})
+
+	t.Run("anonymous request", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subject":"","authenticated":false}`, w.Body.String())
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subject":"test123","authenticated":true}`, w.Body.String())
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		shortJWT := NewJWT("secret", 1*time.Millisecond)
+		token, err := shortJWT.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		time.Sleep(2 * time.Millisecond)
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"token expired"}`, w.Body.String())
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer invalid.token.here")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"invalid token"}`, w.Body.String())
+	})
+
+	t.Run("invalid authorization format", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "InvalidFormat")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"invalid Authorization header format"}`, w.Body.String())
+	})
+}
@@ -6,10 +6,32 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// LimitOption configures LimitMiddleware.
+type LimitOption func(*limitConfig)
+
+type limitConfig struct {
+	responder ErrorResponder
+}
+
+// WithLimitErrorResponder sets the responder of rejected requests.
+//
+// The responder receives a *RateLimitError.
+func WithLimitErrorResponder(responder ErrorResponder) LimitOption {
+	return func(cfg *limitConfig) {
+		cfg.responder = responder
+	}
+}
+
 // LimitMiddleware is a middleware that limits the number of requests at the same time.
 //
 // If the number of requests exceeds the limit, the middleware will return a 429 Too Many Requests status.
-func LimitMiddleware(limit uint64) gin.HandlerFunc {
+// The response body is empty unless an error responder is set with WithLimitErrorResponder.
+func LimitMiddleware(limit uint64, opts ...LimitOption) gin.HandlerFunc {
+	var cfg limitConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	ch := make(chan struct{}, limit)
 	return func(c *gin.Context) {
 		select {
@@ -17,7 +39,12 @@ func LimitMiddleware(limit uint64) gin.HandlerFunc {
 			defer func() { <-ch }()
 			c.Next()
 		default:
-			c.AbortWithStatus(http.StatusTooManyRequests)
+			if cfg.responder == nil {
+				c.AbortWithStatus(http.StatusTooManyRequests)
+				return
+			}
+			cfg.responder.RespondError(c, http.StatusTooManyRequests, &RateLimitError{Limit: limit})
+			c.Abort()
 		}
 	}
 }
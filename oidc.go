@@ -0,0 +1,248 @@
+package gincup
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrOIDCDiscoveryFailed        = errors.New("failed to fetch OpenID Connect discovery document")
+	ErrOIDCInvalidNonce           = errors.New("invalid nonce")
+	ErrOIDCInvalidAuthorizedParty = errors.New("invalid authorized party")
+)
+
+// maxOIDCDiscoveryResponseSize is the maximum size of a discovery document.
+const maxOIDCDiscoveryResponseSize = 1 << 20
+
+// oidcDiscoveryDocument is the part of the OpenID Provider metadata used by
+// the verifier.
+type oidcDiscoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// OIDCVerifier verifies the ID tokens of an OpenID Connect provider.
+//
+// The provider metadata is loaded from /.well-known/openid-configuration,
+// and the keys from its JWKS, which is refreshed in the background.
+type OIDCVerifier struct {
+	clientID string
+	nonce    func(*gin.Context) string
+	jwt      *JWT
+	remote   *RemoteJWKS
+
+	// used only while the verifier is created
+	client      *http.Client
+	jwksOptions []RemoteJWKSOption
+	jwtOptions  []JWTOption
+}
+
+// OIDCOption configures an OIDCVerifier.
+type OIDCOption func(*OIDCVerifier)
+
+// WithOIDCHTTPClient sets the HTTP client used to fetch the discovery
+// document and the JWKS.
+//
+// The default is a client with a 10 seconds timeout.
+func WithOIDCHTTPClient(client *http.Client) OIDCOption {
+	return func(v *OIDCVerifier) {
+		v.client = client
+	}
+}
+
+// WithOIDCNonce sets the function that returns the nonce expected by the
+// middleware, usually read from the session of the login request.
+//
+// The nonce is not checked by default.
+func WithOIDCNonce(nonce func(c *gin.Context) string) OIDCOption {
+	return func(v *OIDCVerifier) {
+		v.nonce = nonce
+	}
+}
+
+// WithOIDCJWKSOptions sets the options of the remote JWKS of the provider.
+func WithOIDCJWKSOptions(opts ...RemoteJWKSOption) OIDCOption {
+	return func(v *OIDCVerifier) {
+		v.jwksOptions = append(v.jwksOptions, opts...)
+	}
+}
+
+// WithOIDCJWTOptions sets the options of the JWT instance that verifies the
+// ID tokens, such as WithLeeway or WithTokenExtractors.
+func WithOIDCJWTOptions(opts ...JWTOption) OIDCOption {
+	return func(v *OIDCVerifier) {
+		v.jwtOptions = append(v.jwtOptions, opts...)
+	}
+}
+
+// NewOIDCVerifier creates an OIDCVerifier for the ID tokens issued by the
+// issuer to the client.
+//
+// The "iss" claim must match the issuer, the "aud" claim must contain the
+// client id, and the "sub", "exp" and "iat" claims are required.
+//
+// If the issuer or the client id is empty, panic.
+// If the discovery document or the JWKS cannot be fetched, return an error.
+func NewOIDCVerifier(ctx context.Context, issuer, clientID string, opts ...OIDCOption) (*OIDCVerifier, error) {
+	if issuer == "" {
+		panic("issuer is required")
+	}
+	if clientID == "" {
+		panic("client id is required")
+	}
+
+	v := &OIDCVerifier{
+		clientID: clientID,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	doc, err := v.discover(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	jwksOptions := append([]RemoteJWKSOption{WithJWKSHTTPClient(v.client)}, v.jwksOptions...)
+	remote, err := NewRemoteJWKS(doc.JWKSURI, jwksOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	jwtOptions := append([]JWTOption{
+		WithIssuer(doc.Issuer),
+		WithAudience(clientID),
+		WithRequiredIssuedAt(),
+	}, v.jwtOptions...)
+
+	v.remote = remote
+	v.jwt = NewJWTWithRemoteJWKS(remote, jwtOptions...)
+	v.client, v.jwksOptions, v.jwtOptions = nil, nil, nil
+	return v, nil
+}
+
+// Verify verifies an ID token and returns its claims.
+//
+// If the nonce is not empty, the "nonce" claim must match it.
+// If the token has several audiences, the "azp" claim must be the client id.
+//
+// If the token is invalid or expired, the function will return an error.
+func (v *OIDCVerifier) Verify(ctx context.Context, token, nonce string) (jwt.MapClaims, error) {
+	claims, err := v.jwt.parseToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if sub, _ := claims.GetSubject(); sub == "" {
+		return nil, ErrJWTInvalidClaims
+	}
+	if _, ok := numericDateClaim(claims, "exp"); !ok {
+		return nil, ErrJWTInvalidClaims
+	}
+
+	if nonce != "" {
+		got, _ := claims["nonce"].(string)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1 {
+			return nil, ErrOIDCInvalidNonce
+		}
+	}
+
+	azp, hasAZP := claims["azp"].(string)
+	if hasAZP && azp != v.clientID {
+		return nil, ErrOIDCInvalidAuthorizedParty
+	}
+	if !hasAZP && len(stringsClaim(claims, "aud")) > 1 {
+		return nil, ErrOIDCInvalidAuthorizedParty
+	}
+	return claims, nil
+}
+
+// Middleware is a middleware that verifies an ID token and sets the subject
+// and the claims to the context as with MiddlewareWithSubject.
+//
+// If a nonce function is set and returns an empty nonce, the token is rejected.
+//
+// If the token is invalid or expired, the middleware will return a 401 Unauthorized status.
+func (v *OIDCVerifier) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := v.jwt.extractToken(c)
+		if err != nil {
+			v.jwt.abort(c, err)
+			return
+		}
+
+		var nonce string
+		if v.nonce != nil {
+			if nonce = v.nonce(c); nonce == "" {
+				v.jwt.abort(c, ErrOIDCInvalidNonce)
+				return
+			}
+		}
+
+		claims, err := v.Verify(c.Request.Context(), token, nonce)
+		if err != nil {
+			v.jwt.abort(c, err)
+			return
+		}
+
+		if err := setClaims(c, claims); err != nil {
+			v.jwt.abort(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetSubjectFromGinContext gets the subject from the gin context.
+//
+// If the subject is not found, return an empty string.
+func (v *OIDCVerifier) GetSubjectFromGinContext(c *gin.Context) string {
+	return v.jwt.GetSubjectFromGinContext(c)
+}
+
+// Close stops the background refresh of the JWKS.
+func (v *OIDCVerifier) Close() {
+	v.remote.Close()
+}
+
+// discover fetches the discovery document of the issuer.
+func (v *OIDCVerifier) discover(ctx context.Context, issuer string) (*oidcDiscoveryDocument, error) {
+	url := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Join(ErrOIDCDiscoveryFailed, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, errors.Join(ErrOIDCDiscoveryFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrOIDCDiscoveryFailed
+	}
+
+	var doc oidcDiscoveryDocument
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOIDCDiscoveryResponseSize)).Decode(&doc); err != nil {
+		return nil, errors.Join(ErrOIDCDiscoveryFailed, err)
+	}
+
+	// the issuer of the document must be the one it was fetched from,
+	// OpenID Connect Discovery 1.0 section 4.3
+	if doc.Issuer != issuer || doc.JWKSURI == "" {
+		return nil, ErrOIDCDiscoveryFailed
+	}
+	return &doc, nil
+}
@@ -0,0 +1,181 @@
+package gincup
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeIdP serves the discovery document and the JWKS of an OpenID
+// provider whose issuer is the URL of the server.
+func newFakeIdP(t *testing.T) (*JWT, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	var provider *JWT
+	router := gin.New()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	provider = NewJWTWithPrivateKey(key, 1*time.Hour, WithIssuer(server.URL))
+	assert.NoError(t, provider.RotateKey("k1", key))
+
+	router.GET("/.well-known/openid-configuration", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"issuer":   server.URL,
+			"jwks_uri": server.URL + "/jwks.json",
+		})
+	})
+	router.GET("/jwks.json", provider.JWKSHandler(time.Minute))
+	return provider, server.URL
+}
+
+func TestOIDCVerifier(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	provider, issuer := newFakeIdP(t)
+
+	verifier, err := NewOIDCVerifier(ctx, issuer, "client")
+	assert.NoError(t, err)
+	defer verifier.Close()
+
+	idToken := func(claims map[string]any) string {
+		token, err := GenerateTokenWithClaims(provider, claims)
+		assert.NoError(t, err)
+		return token
+	}
+
+	t.Run("valid ID token", func(t *testing.T) {
+		claims, err := verifier.Verify(ctx, idToken(map[string]any{"sub": "test123", "aud": "client", "nonce": "n-0S6"}), "n-0S6")
+		assert.NoError(t, err)
+		assert.Equal(t, "test123", claims["sub"])
+	})
+
+	t.Run("wrong audience", func(t *testing.T) {
+		_, err := verifier.Verify(ctx, idToken(map[string]any{"sub": "test123", "aud": "other"}), "")
+		assert.ErrorIs(t, err, ErrJWTInvalidToken)
+	})
+
+	t.Run("wrong issuer", func(t *testing.T) {
+		_, err := verifier.Verify(ctx, idToken(map[string]any{"sub": "test123", "aud": "client", "iss": "https://evil.example.com"}), "")
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong nonce", func(t *testing.T) {
+		_, err := verifier.Verify(ctx, idToken(map[string]any{"sub": "test123", "aud": "client", "nonce": "n-0S6"}), "other")
+		assert.ErrorIs(t, err, ErrOIDCInvalidNonce)
+
+		_, err = verifier.Verify(ctx, idToken(map[string]any{"sub": "test123", "aud": "client"}), "n-0S6")
+		assert.ErrorIs(t, err, ErrOIDCInvalidNonce)
+	})
+
+	t.Run("authorized party", func(t *testing.T) {
+		_, err := verifier.Verify(ctx, idToken(map[string]any{"sub": "test123", "aud": []string{"client", "api"}, "azp": "client"}), "")
+		assert.NoError(t, err)
+
+		_, err = verifier.Verify(ctx, idToken(map[string]any{"sub": "test123", "aud": []string{"client", "api"}}), "")
+		assert.ErrorIs(t, err, ErrOIDCInvalidAuthorizedParty)
+
+		_, err = verifier.Verify(ctx, idToken(map[string]any{"sub": "test123", "aud": "client", "azp": "other"}), "")
+		assert.ErrorIs(t, err, ErrOIDCInvalidAuthorizedParty)
+	})
+
+	t.Run("missing subject", func(t *testing.T) {
+		_, err := verifier.Verify(ctx, idToken(map[string]any{"aud": "client"}), "")
+		assert.ErrorIs(t, err, ErrJWTInvalidClaims)
+	})
+
+	t.Run("token of another issuer", func(t *testing.T) {
+		other, _ := newFakeIdP(t)
+		token, err := GenerateTokenWithClaims(other, map[string]any{"sub": "test123", "aud": "client", "iss": issuer})
+		assert.NoError(t, err)
+
+		_, err = verifier.Verify(ctx, token, "")
+		assert.Error(t, err)
+	})
+}
+
+func TestOIDCVerifierMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider, issuer := newFakeIdP(t)
+
+	verifier, err := NewOIDCVerifier(context.Background(), issuer, "client", WithOIDCNonce(func(c *gin.Context) string {
+		nonce, _ := c.Cookie("nonce")
+		return nonce
+	}))
+	assert.NoError(t, err)
+	defer verifier.Close()
+
+	router := gin.New()
+	router.GET("/test", verifier.Middleware(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"subject": verifier.GetSubjectFromGinContext(c)})
+	})
+
+	token, err := GenerateTokenWithClaims(provider, map[string]any{"sub": "test123", "aud": "client", "nonce": "n-0S6"})
+	assert.NoError(t, err)
+
+	request := func(nonce string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if nonce != "" {
+			req.AddCookie(&http.Cookie{Name: "nonce", Value: nonce})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		w := request("n-0S6")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subject":"test123"}`, w.Body.String())
+	})
+
+	t.Run("missing nonce", func(t *testing.T) {
+		w := request("")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("wrong nonce", func(t *testing.T) {
+		w := request("other")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"invalid nonce"}`, w.Body.String())
+		assert.Equal(t, `Bearer error="invalid_token", error_description="invalid nonce"`, w.Header().Get("WWW-Authenticate"))
+	})
+}
+
+func TestNewOIDCVerifier(t *testing.T) {
+	t.Run("issuer mismatch", func(t *testing.T) {
+		_, issuer := newFakeIdP(t)
+
+		_, err := NewOIDCVerifier(context.Background(), issuer+"/other", "client")
+		assert.ErrorIs(t, err, ErrOIDCDiscoveryFailed)
+	})
+
+	t.Run("unreachable provider", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		_, err := NewOIDCVerifier(context.Background(), server.URL, "client")
+		assert.ErrorIs(t, err, ErrOIDCDiscoveryFailed)
+	})
+
+	t.Run("missing arguments panic", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewOIDCVerifier(context.Background(), "", "client")
+		})
+		assert.Panics(t, func() {
+			NewOIDCVerifier(context.Background(), "https://idp.example.com", "")
+		})
+	})
+}
@@ -0,0 +1,347 @@
+package gincup
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenExpired  = errors.New("refresh token expired")
+	ErrRefreshTokenReused   = errors.New("refresh token reused")
+	ErrRefreshTokenRevoked  = errors.New("refresh token revoked")
+	ErrRefreshTokenRequired = errors.New("refresh_token is required")
+)
+
+// RefreshToken is a refresh token kept by a RefreshTokenStore.
+//
+// The opaque token itself is never stored, only its SHA-256 hash as ID.
+// Version is the token version of the subject when the token was issued,
+// see WithTokenVersion. AuthTime is the time of the login that started the
+// token family; it is kept across refreshes.
+type RefreshToken struct {
+	ID        string
+	FamilyID  string
+	Subject   string
+	Version   uint64
+	AuthTime  time.Time
+	ExpiresAt time.Time
+}
+
+// RefreshTokenStore stores refresh tokens.
+//
+// The tokens issued from the same login share a family id, so that the whole
+// family can be revoked when a token is reused.
+type RefreshTokenStore interface {
+	// Create saves a new refresh token.
+	Create(ctx context.Context, token RefreshToken) error
+
+	// Consume marks the refresh token as used and returns it.
+	//
+	// It must be atomic: a token can only be consumed once.
+	// If the token does not exist, return ErrRefreshTokenNotFound.
+	// If the token has expired, return ErrRefreshTokenExpired.
+	// If the family of the token has been revoked, return ErrRefreshTokenRevoked.
+	// If the token has already been used, return it with ErrRefreshTokenReused.
+	Consume(ctx context.Context, id string) (RefreshToken, error)
+
+	// RevokeFamily revokes every refresh token of the family.
+	RevokeFamily(ctx context.Context, familyID string) error
+}
+
+// MemoryRefreshTokenStore is a RefreshTokenStore that keeps refresh tokens
+// in memory.
+//
+// Expired tokens are removed when new tokens are created.
+type MemoryRefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*memoryRefreshToken
+}
+
+type memoryRefreshToken struct {
+	token   RefreshToken
+	used    bool
+	revoked bool
+}
+
+// NewMemoryRefreshTokenStore creates a new MemoryRefreshTokenStore.
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{
+		tokens: map[string]*memoryRefreshToken{},
+	}
+}
+
+// Create implements the RefreshTokenStore interface.
+func (s *MemoryRefreshTokenStore) Create(ctx context.Context, token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range s.tokens {
+		if now.After(t.token.ExpiresAt) {
+			delete(s.tokens, id)
+		}
+	}
+
+	s.tokens[token.ID] = &memoryRefreshToken{token: token}
+	return nil
+}
+
+// Consume implements the RefreshTokenStore interface.
+func (s *MemoryRefreshTokenStore) Consume(ctx context.Context, id string) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok {
+		return RefreshToken{}, ErrRefreshTokenNotFound
+	}
+
+	switch {
+	case time.Now().After(t.token.ExpiresAt):
+		delete(s.tokens, id)
+		return RefreshToken{}, ErrRefreshTokenExpired
+	case t.revoked:
+		return RefreshToken{}, ErrRefreshTokenRevoked
+	case t.used:
+		return t.token, ErrRefreshTokenReused
+	}
+
+	t.used = true
+	return t.token, nil
+}
+
+// RevokeFamily implements the RefreshTokenStore interface.
+func (s *MemoryRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.token.FamilyID == familyID {
+			t.revoked = true
+		}
+	}
+	return nil
+}
+
+// TokenPair is an access token with its refresh token, encoded like an
+// OAuth 2.0 token response.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Refresher issues access tokens with opaque refresh tokens.
+//
+// Refresh tokens are rotated on every use. If a refresh token is used twice,
+// its whole family is revoked, since one of the two callers stole it.
+//
+// Access tokens carry the time of the login in the "auth_time" claim, so a
+// refresh neither extends a sliding session beyond its maximum lifetime nor
+// passes a RequireStepUp maximum age.
+type Refresher struct {
+	jwt            *JWT
+	store          RefreshTokenStore
+	expireDuration time.Duration
+}
+
+// NewRefresher creates a new Refresher that issues access tokens with j and
+// refresh tokens that expire after the expire duration.
+//
+// If j or the store is nil, panic.
+// If the expire duration is less than or equal to 0, panic.
+func NewRefresher(j *JWT, store RefreshTokenStore, expireDuration time.Duration) *Refresher {
+	if j == nil {
+		panic("jwt is required")
+	}
+
+	if store == nil {
+		panic("store is required")
+	}
+
+	if expireDuration <= 0 {
+		panic("expire duration must be greater than 0")
+	}
+
+	return &Refresher{
+		jwt:            j,
+		store:          store,
+		expireDuration: expireDuration,
+	}
+}
+
+// IssueTokenPair issues an access token and a refresh token for the subject,
+// starting a new token family. It is usually called after a login.
+func (r *Refresher) IssueTokenPair(ctx context.Context, sub string) (TokenPair, error) {
+	familyID, err := randomToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return r.issue(ctx, sub, familyID, r.jwt.now())
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token.
+//
+// The refresh token expires with the clock set by WithTimeFunc, and cannot
+// be used again. If it has already been used, every refresh token of its
+// family is revoked and ErrRefreshTokenReused is returned.
+//
+// If the token version of the subject has changed since the refresh token
+// was issued, its family is revoked and ErrRefreshTokenRevoked is returned.
+// If the sliding session of the JWT instance has exceeded its maximum
+// lifetime, ErrJWTSessionExpired is returned.
+func (r *Refresher) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	token, err := r.store.Consume(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			if revokeErr := r.store.RevokeFamily(ctx, token.FamilyID); revokeErr != nil {
+				return TokenPair{}, revokeErr
+			}
+		}
+		return TokenPair{}, err
+	}
+
+	// the store may check the expiration with another clock than WithTimeFunc
+	if r.jwt.now().After(token.ExpiresAt) {
+		return TokenPair{}, ErrRefreshTokenExpired
+	}
+
+	version, err := r.jwt.currentTokenVersion(ctx, token.Subject)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if version != token.Version {
+		if err := r.store.RevokeFamily(ctx, token.FamilyID); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrRefreshTokenRevoked
+	}
+
+	if r.jwt.sessionExpired(token.AuthTime) {
+		return TokenPair{}, ErrJWTSessionExpired
+	}
+
+	return r.issue(ctx, token.Subject, token.FamilyID, token.AuthTime)
+}
+
+// Revoke revokes the refresh token and every refresh token of its family.
+// It is usually called on logout.
+func (r *Refresher) Revoke(ctx context.Context, refreshToken string) error {
+	token, err := r.store.Consume(ctx, hashRefreshToken(refreshToken))
+	if err != nil && !errors.Is(err, ErrRefreshTokenReused) {
+		return err
+	}
+
+	return r.store.RevokeFamily(ctx, token.FamilyID)
+}
+
+// Handler is a handler that exchanges a refresh token for a new token pair.
+//
+// The refresh token is read from the "refresh_token" field of a JSON or
+// form body. The response is a TokenPair.
+//
+// If the refresh token is missing, the handler will return a 400 Bad Request status.
+// If the refresh token is invalid, expired, revoked or reused, or the session
+// has expired, the handler will return a 401 Unauthorized status.
+//
+// Errors are written by the error responder of the JWT instance.
+func (r *Refresher) Handler() gin.HandlerFunc {
+	type request struct {
+		RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	}
+
+	return func(c *gin.Context) {
+		var req request
+		if err := c.ShouldBind(&req); err != nil || req.RefreshToken == "" {
+			r.jwt.respondError(c, http.StatusBadRequest, ErrRefreshTokenRequired)
+			return
+		}
+
+		pair, err := r.Refresh(c.Request.Context(), req.RefreshToken)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrRefreshTokenNotFound),
+				errors.Is(err, ErrRefreshTokenExpired),
+				errors.Is(err, ErrRefreshTokenRevoked),
+				errors.Is(err, ErrRefreshTokenReused),
+				errors.Is(err, ErrJWTSessionExpired):
+				r.jwt.respondError(c, http.StatusUnauthorized, err)
+			default:
+				r.jwt.respondError(c, http.StatusInternalServerError, err)
+			}
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, pair)
+	}
+}
+
+// issue issues a token pair in the token family of the login at authTime.
+func (r *Refresher) issue(ctx context.Context, sub, familyID string, authTime time.Time) (TokenPair, error) {
+	claims := jwt.MapClaims{"sub": sub}
+	if !authTime.IsZero() {
+		claims["auth_time"] = jwt.NewNumericDate(authTime)
+	}
+	accessToken, err := r.jwt.signClaims(claims)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	version, err := r.jwt.currentTokenVersion(ctx, sub)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	err = r.store.Create(ctx, RefreshToken{
+		ID:        hashRefreshToken(refreshToken),
+		FamilyID:  familyID,
+		Subject:   sub,
+		Version:   version,
+		AuthTime:  authTime,
+		ExpiresAt: r.jwt.now().Add(r.expireDuration),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(r.jwt.expireDuration / time.Second),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// randomToken returns 32 random bytes encoded in base64url.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken returns the id under which a refresh token is stored.
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,160 @@
+package gincup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefresher(t *testing.T) {
+	ctx := context.Background()
+	j := NewJWT("secret", 1*time.Hour)
+
+	t.Run("issue and refresh", func(t *testing.T) {
+		r := NewRefresher(j, NewMemoryRefreshTokenStore(), 24*time.Hour)
+
+		pair, err := r.IssueTokenPair(ctx, "test123")
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer", pair.TokenType)
+		assert.Equal(t, int64(3600), pair.ExpiresIn)
+		assert.NotEmpty(t, pair.RefreshToken)
+
+		sub, err := j.validateTokenAndGetSubject(pair.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "test123", sub)
+
+		next, err := r.Refresh(ctx, pair.RefreshToken)
+		assert.NoError(t, err)
+		assert.NotEqual(t, pair.RefreshToken, next.RefreshToken)
+
+		sub, err = j.validateTokenAndGetSubject(next.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "test123", sub)
+	})
+
+	t.Run("reuse revokes the family", func(t *testing.T) {
+		r := NewRefresher(j, NewMemoryRefreshTokenStore(), 24*time.Hour)
+
+		pair, err := r.IssueTokenPair(ctx, "test123")
+		assert.NoError(t, err)
+		next, err := r.Refresh(ctx, pair.RefreshToken)
+		assert.NoError(t, err)
+
+		_, err = r.Refresh(ctx, pair.RefreshToken)
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+		_, err = r.Refresh(ctx, next.RefreshToken)
+		assert.ErrorIs(t, err, ErrRefreshTokenRevoked)
+	})
+
+	t.Run("other families are kept", func(t *testing.T) {
+		r := NewRefresher(j, NewMemoryRefreshTokenStore(), 24*time.Hour)
+
+		stolen, err := r.IssueTokenPair(ctx, "test123")
+		assert.NoError(t, err)
+		other, err := r.IssueTokenPair(ctx, "test123")
+		assert.NoError(t, err)
+
+		_, err = r.Refresh(ctx, stolen.RefreshToken)
+		assert.NoError(t, err)
+		_, err = r.Refresh(ctx, stolen.RefreshToken)
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+		_, err = r.Refresh(ctx, other.RefreshToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now := time.Now()
+		j := NewJWT("secret", 15*time.Minute, WithTimeFunc(func() time.Time { return now }))
+		r := NewRefresher(j, NewMemoryRefreshTokenStore(), 24*time.Hour)
+
+		pair, err := r.IssueTokenPair(ctx, "test123")
+		assert.NoError(t, err)
+
+		now = now.Add(23 * time.Hour)
+		pair, err = r.Refresh(ctx, pair.RefreshToken)
+		assert.NoError(t, err)
+
+		now = now.Add(25 * time.Hour)
+		_, err = r.Refresh(ctx, pair.RefreshToken)
+		assert.ErrorIs(t, err, ErrRefreshTokenExpired)
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		r := NewRefresher(j, NewMemoryRefreshTokenStore(), 24*time.Hour)
+
+		pair, err := r.IssueTokenPair(ctx, "test123")
+		assert.NoError(t, err)
+		assert.NoError(t, r.Revoke(ctx, pair.RefreshToken))
+
+		_, err = r.Refresh(ctx, pair.RefreshToken)
+		assert.ErrorIs(t, err, ErrRefreshTokenRevoked)
+
+		_, err = r.Refresh(ctx, "unknown")
+		assert.ErrorIs(t, err, ErrRefreshTokenNotFound)
+	})
+}
+
+func TestRefresherHandler(t *testing.T) {
+	j := NewJWT("secret", 1*time.Hour)
+	r := NewRefresher(j, NewMemoryRefreshTokenStore(), 24*time.Hour)
+
+	router := gin.New()
+	router.POST("/token/refresh", r.Handler())
+
+	pair, err := r.IssueTokenPair(context.Background(), "test123")
+	assert.NoError(t, err)
+
+	t.Run("json body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/token/refresh", strings.NewReader(`{"refresh_token":"`+pair.RefreshToken+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		var next TokenPair
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &next))
+		assert.NoError(t, j.validateToken(next.AccessToken))
+
+		t.Run("form body", func(t *testing.T) {
+			form := url.Values{"refresh_token": {next.RefreshToken}}
+			req := httptest.NewRequest("POST", "/token/refresh", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	})
+
+	t.Run("reused token", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/token/refresh", strings.NewReader(`{"refresh_token":"`+pair.RefreshToken+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"invalid refresh token"}`, w.Body.String())
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/token/refresh", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"message":"refresh_token is required"}`, w.Body.String())
+	})
+}
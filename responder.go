@@ -0,0 +1,171 @@
+package gincup
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrRateLimited = errors.New("too many requests")
+)
+
+// RateLimitError is the error of a request rejected by LimitMiddleware.
+type RateLimitError struct {
+	// Limit is the number of requests allowed at the same time.
+	Limit uint64
+}
+
+// Error implements the error interface.
+func (e *RateLimitError) Error() string {
+	return ErrRateLimited.Error()
+}
+
+// Is reports whether the target is ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// ErrorResponder writes the response of a request rejected by a gincup
+// middleware or handler.
+//
+// The request is aborted after RespondError returns.
+type ErrorResponder interface {
+	// RespondError writes the response with the status code and the error
+	// that caused the rejection, such as ErrJWTTokenExpired or a *RateLimitError.
+	RespondError(c *gin.Context, status int, err error)
+}
+
+// ErrorResponderFunc is a function that implements the ErrorResponder interface.
+type ErrorResponderFunc func(c *gin.Context, status int, err error)
+
+// RespondError implements the ErrorResponder interface.
+func (f ErrorResponderFunc) RespondError(c *gin.Context, status int, err error) {
+	f(c, status, err)
+}
+
+// DefaultErrorResponder writes a JSON body in the format {"message": "<message>"}.
+var DefaultErrorResponder ErrorResponder = ErrorResponderFunc(func(c *gin.Context, status int, err error) {
+	c.JSON(status, gin.H{"message": ErrorMessage(status, err)})
+})
+
+// ProblemDetailsResponder writes an RFC 7807 "application/problem+json" body.
+//
+// A *RateLimitError adds a "limit" member, and an
+// *InsufficientUserAuthenticationError the "acr_values" and "max_age" members.
+type ProblemDetailsResponder struct {
+	// Type is the URI reference that identifies the problem type.
+	// The default is "about:blank".
+	Type string
+}
+
+// RespondError implements the ErrorResponder interface.
+func (r ProblemDetailsResponder) RespondError(c *gin.Context, status int, err error) {
+	problemType := r.Type
+	if problemType == "" {
+		problemType = "about:blank"
+	}
+
+	problem := gin.H{
+		"type":   problemType,
+		"title":  http.StatusText(status),
+		"status": status,
+		"detail": ErrorMessage(status, err),
+	}
+
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		problem["limit"] = rateLimitErr.Limit
+	}
+
+	var stepUpErr *InsufficientUserAuthenticationError
+	if errors.As(err, &stepUpErr) {
+		if len(stepUpErr.ACRValues) > 0 {
+			problem["acr_values"] = strings.Join(stepUpErr.ACRValues, " ")
+		}
+		if stepUpErr.MaxAge > 0 {
+			problem["max_age"] = int64(stepUpErr.MaxAge / time.Second)
+		}
+	}
+
+	c.Render(status, problemRender{problem})
+}
+
+// problemRender renders a problem details object.
+type problemRender struct {
+	problem gin.H
+}
+
+// Render implements the render.Render interface.
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.problem)
+}
+
+// WriteContentType implements the render.Render interface.
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/problem+json")
+}
+
+// publicErrors are the errors whose message can be sent to clients.
+var publicErrors = []error{
+	ErrJWTInvalidAuthorizationHeader,
+	ErrJWTTokenNotFound,
+	ErrJWTTokenExpired,
+	ErrJWTTokenRevoked,
+	ErrJWTSessionExpired,
+	ErrJWTInvalidToken,
+	ErrJWTInvalidClaims,
+	ErrOIDCInvalidNonce,
+	ErrOIDCInvalidAuthorizedParty,
+	ErrJWTInsufficientScope,
+	ErrJWTInsufficientUserAuthentication,
+	ErrJWTForbidden,
+	ErrRefreshTokenRequired,
+	ErrInvalidClient,
+	ErrIntrospectionTokenRequired,
+	ErrDPoPInvalidProof,
+	ErrDPoPKeyMismatch,
+	ErrDPoPBoundToken,
+	ErrTokenExchangeInvalidRequest,
+	ErrTokenExchangeInvalidScope,
+	ErrTokenExchangeDenied,
+	ErrRateLimited,
+}
+
+// ErrorMessage returns the message sent to clients for an error.
+//
+// Errors that may leak internal details, such as storage failures, get the
+// status text of the status code.
+func ErrorMessage(status int, err error) string {
+	switch {
+	case errors.Is(err, ErrRefreshTokenNotFound),
+		errors.Is(err, ErrRefreshTokenExpired),
+		errors.Is(err, ErrRefreshTokenRevoked),
+		errors.Is(err, ErrRefreshTokenReused):
+		// do not tell a thief that the token family has been revoked
+		return "invalid refresh token"
+	}
+
+	for _, e := range publicErrors {
+		if errors.Is(err, e) {
+			return e.Error()
+		}
+	}
+	return strings.ToLower(http.StatusText(status))
+}
+
+// respondError aborts the request with the error responder of the JWT instance.
+func (j *JWT) respondError(c *gin.Context, status int, err error) {
+	responder := j.responder
+	if responder == nil {
+		responder = DefaultErrorResponder
+	}
+
+	responder.RespondError(c, status, err)
+	c.Abort()
+}
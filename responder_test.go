@@ -0,0 +1,113 @@
+package gincup
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorMessage(t *testing.T) {
+	assert.Equal(t, "token expired", ErrorMessage(http.StatusUnauthorized, ErrJWTTokenExpired))
+	assert.Equal(t, "invalid Authorization header format", ErrorMessage(http.StatusUnauthorized, missingHeaderError()))
+	assert.Equal(t, "invalid refresh token", ErrorMessage(http.StatusUnauthorized, ErrRefreshTokenReused))
+	assert.Equal(t, "too many requests", ErrorMessage(http.StatusTooManyRequests, &RateLimitError{Limit: 1}))
+	assert.Equal(t, "internal server error", ErrorMessage(http.StatusInternalServerError, errors.New("database is down")))
+}
+
+// missingHeaderError returns the error of a missing Authorization header.
+func missingHeaderError() error {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	_, err := HeaderExtractor("Authorization", "Bearer").ExtractToken(c)
+	return err
+}
+
+func TestProblemDetailsResponder(t *testing.T) {
+	t.Run("jwt", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour, WithErrorResponder(ProblemDetailsResponder{}))
+
+		router := gin.New()
+		router.GET("/test", j.MiddlewareWithSubject(), j.RequireScopes("admin"), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer invalid.token.here")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"type":"about:blank","title":"Unauthorized","status":401,"detail":"invalid token"}`, w.Body.String())
+
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		req = httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Forbidden","status":403,"detail":"insufficient scope"}`, w.Body.String())
+	})
+
+	t.Run("limiter", func(t *testing.T) {
+		router := gin.New()
+		router.Use(LimitMiddleware(0, WithLimitErrorResponder(ProblemDetailsResponder{Type: "https://example.com/problems/rate-limit"})))
+		router.GET("/test", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.JSONEq(t, `{"type":"https://example.com/problems/rate-limit","title":"Too Many Requests","status":429,"detail":"too many requests","limit":0}`, w.Body.String())
+	})
+}
+
+func TestCustomErrorResponder(t *testing.T) {
+	var received error
+	responder := ErrorResponderFunc(func(c *gin.Context, status int, err error) {
+		received = err
+		c.JSON(status, gin.H{"error": gin.H{"code": status}})
+	})
+
+	j := NewJWT("secret", 1*time.Millisecond, WithErrorResponder(responder))
+
+	router := gin.New()
+	router.Use(j.Middleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	token, err := j.GenerateToken()
+	assert.NoError(t, err)
+
+	time.Sleep(2 * time.Millisecond)
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"error":{"code":401}}`, w.Body.String())
+	assert.ErrorIs(t, received, ErrJWTTokenExpired)
+
+	t.Run("rate limit error", func(t *testing.T) {
+		var err error = &RateLimitError{Limit: 10}
+		assert.ErrorIs(t, err, ErrRateLimited)
+
+		var rateLimitErr *RateLimitError
+		assert.True(t, errors.As(err, &rateLimitErr))
+		assert.Equal(t, uint64(10), rateLimitErr.Limit)
+	})
+}
@@ -0,0 +1,147 @@
+package gincup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrJWTTokenRevoked            = errors.New("token revoked")
+	ErrJWTTokenIDMissing          = errors.New("token has no jti claim")
+	ErrJWTRevocationStoreRequired = errors.New("revocation store is required")
+)
+
+// RevocationStore stores the ids ("jti" claims) of revoked tokens.
+type RevocationStore interface {
+	// Revoke revokes the token with the id. The token expires at expiresAt,
+	// so the store may forget it afterwards.
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+
+	// IsRevoked reports whether the token with the id has been revoked.
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// MemoryRevocationStore is a RevocationStore that keeps revoked token ids
+// in memory.
+//
+// Each entry is removed once its token has expired.
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+
+	// now returns the current time, replaced in tests
+	now func() time.Time
+}
+
+// NewMemoryRevocationStore creates a new MemoryRevocationStore.
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		revoked: map[string]time.Time{},
+		now:     time.Now,
+	}
+}
+
+// Revoke implements the RevocationStore interface.
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for id, exp := range s.revoked {
+		if now.After(exp) {
+			delete(s.revoked, id)
+		}
+	}
+
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+// IsRevoked implements the RevocationStore interface.
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exp, ok := s.revoked[jti]
+	if !ok {
+		return false, nil
+	}
+	if s.now().After(exp) {
+		delete(s.revoked, jti)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Revoke revokes a token, usually on logout.
+//
+// The token is rejected by the middlewares until it expires, including the
+// leeway set by WithLeeway.
+// Revoking an expired token does nothing.
+//
+// If no revocation store is set, return ErrJWTRevocationStoreRequired.
+// If the token is invalid or has no "jti" claim, return an error.
+func (j *JWT) Revoke(ctx context.Context, token string) error {
+	if j.revocations == nil {
+		return ErrJWTRevocationStoreRequired
+	}
+
+	claims, err := j.verifyToken(token)
+	if err != nil {
+		if errors.Is(err, ErrJWTTokenExpired) {
+			return nil
+		}
+		return err
+	}
+
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return ErrJWTTokenIDMissing
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return ErrJWTInvalidToken
+	}
+
+	// The parser accepts the token until exp plus the leeway.
+	return j.revocations.Revoke(ctx, jti, exp.Time.Add(j.leeway))
+}
+
+// checkRevocation rejects the claims of a revoked token.
+//
+// Tokens without a "jti" claim cannot be revoked.
+func (j *JWT) checkRevocation(ctx context.Context, claims jwt.MapClaims) error {
+	if j.revocations == nil {
+		return nil
+	}
+
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return nil
+	}
+
+	revoked, err := j.revocations.IsRevoked(ctx, jti)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrJWTTokenRevoked
+	}
+	return nil
+}
+
+// newTokenID returns a random token id for the "jti" claim.
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
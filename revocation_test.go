@@ -0,0 +1,139 @@
+package gincup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTRevoke(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("unique jti", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour)
+
+		token1, err := j.GenerateToken()
+		assert.NoError(t, err)
+		token2, err := j.GenerateToken()
+		assert.NoError(t, err)
+
+		claims1, err := j.parseToken(ctx, token1)
+		assert.NoError(t, err)
+		claims2, err := j.parseToken(ctx, token2)
+		assert.NoError(t, err)
+
+		assert.NotEmpty(t, claims1["jti"])
+		assert.NotEqual(t, claims1["jti"], claims2["jti"])
+	})
+
+	t.Run("middleware rejects revoked token", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour, WithRevocationStore(NewMemoryRevocationStore()))
+
+		router := gin.New()
+		router.Use(j.Middleware())
+		router.GET("/test", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		})
+
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+		other, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		assert.NoError(t, j.Revoke(ctx, token))
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"token revoked"}`, w.Body.String())
+
+		req = httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+other)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		token, err := NewJWT("secret", 1*time.Hour).GenerateToken()
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, NewJWT("secret", 1*time.Hour).Revoke(ctx, token), ErrJWTRevocationStoreRequired)
+
+		j := NewJWT("secret", 1*time.Hour, WithRevocationStore(NewMemoryRevocationStore()))
+		assert.ErrorIs(t, j.Revoke(ctx, "invalid.token.here"), ErrJWTInvalidToken)
+
+		token, err = GenerateTokenWithClaims(j, jwt.MapClaims{"jti": ""})
+		assert.NoError(t, err)
+		assert.ErrorIs(t, j.Revoke(ctx, token), ErrJWTTokenIDMissing)
+	})
+
+	t.Run("revoked during the leeway", func(t *testing.T) {
+		now := time.Now()
+		clock := func() time.Time { return now }
+		store := NewMemoryRevocationStore()
+		store.now = clock
+		j := NewJWT("secret", 1*time.Hour, WithLeeway(1*time.Hour), WithTimeFunc(clock), WithRevocationStore(store))
+
+		token, err := j.GenerateToken()
+		assert.NoError(t, err)
+		assert.NoError(t, j.Revoke(ctx, token))
+
+		now = now.Add(90 * time.Minute)
+		_, err = j.parseToken(ctx, token)
+		assert.ErrorIs(t, err, ErrJWTTokenRevoked)
+
+		now = now.Add(time.Hour)
+		_, err = j.parseToken(ctx, token)
+		assert.ErrorIs(t, err, ErrJWTTokenExpired)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		store := NewMemoryRevocationStore()
+		j := NewJWT("secret", 1*time.Millisecond, WithRevocationStore(store))
+
+		token, err := j.GenerateToken()
+		assert.NoError(t, err)
+
+		time.Sleep(2 * time.Millisecond)
+
+		assert.NoError(t, j.Revoke(ctx, token))
+		assert.Empty(t, store.revoked)
+	})
+}
+
+func TestMemoryRevocationStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRevocationStore()
+
+	assert.NoError(t, store.Revoke(ctx, "short", time.Now().Add(time.Millisecond)))
+	assert.NoError(t, store.Revoke(ctx, "long", time.Now().Add(time.Hour)))
+
+	revoked, err := store.IsRevoked(ctx, "short")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	time.Sleep(2 * time.Millisecond)
+
+	revoked, err = store.IsRevoked(ctx, "short")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "long")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "unknown")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
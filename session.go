@@ -0,0 +1,131 @@
+package gincup
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrJWTSessionExpired = errors.New("session expired")
+)
+
+// SlidingSession configures the renewal of tokens close to their expiration.
+//
+// A token is renewed when it expires within Window. The renewed token keeps
+// the claims of the original token, including "auth_time", the time of the
+// login. No token is valid after auth_time plus MaxLifetime, so a session
+// ends at the latest MaxLifetime after its login.
+type SlidingSession struct {
+	// Window is the time before expiration during which tokens are renewed.
+	Window time.Duration
+
+	// MaxLifetime is the maximum lifetime of a session.
+	MaxLifetime time.Duration
+
+	// Header is the response header that carries the renewed token.
+	// The default is "X-Renewed-Token".
+	Header string
+
+	// Cookie, if set, is a template of the cookie that carries the renewed
+	// token. Its value and expiration are set from the renewed token.
+	Cookie *http.Cookie
+}
+
+// checkSessionLifetime rejects the claims of a token whose session has
+// exceeded its maximum lifetime.
+//
+// Tokens without an "auth_time" claim are checked against their "iat" claim.
+func (j *JWT) checkSessionLifetime(claims jwt.MapClaims) error {
+	if j.session == nil {
+		return nil
+	}
+
+	authTime, ok := sessionStart(claims)
+	if !ok {
+		return nil
+	}
+	if j.sessionExpired(authTime) {
+		return ErrJWTSessionExpired
+	}
+	return nil
+}
+
+// sessionExpired reports whether the sliding session of the login at
+// authTime has exceeded its maximum lifetime.
+func (j *JWT) sessionExpired(authTime time.Time) bool {
+	if j.session == nil || authTime.IsZero() {
+		return false
+	}
+	return j.now().After(authTime.Add(j.session.MaxLifetime + j.leeway))
+}
+
+// renewSession sends a renewed token if the token is within the renewal window.
+//
+// The request is not affected if the token cannot be renewed.
+func (j *JWT) renewSession(c *gin.Context, claims jwt.MapClaims) {
+	if j.session == nil {
+		return
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return
+	}
+
+	now := j.now()
+	if exp.Sub(now) > j.session.Window {
+		return
+	}
+
+	authTime, ok := sessionStart(claims)
+	if !ok {
+		authTime = now
+	}
+
+	newExp := now.Add(j.expireDuration)
+	if end := authTime.Add(j.session.MaxLifetime); newExp.After(end) {
+		newExp = end
+	}
+	if !newExp.After(exp.Time) {
+		// the session has reached its maximum lifetime
+		return
+	}
+
+	renewed := jwt.MapClaims{}
+	for name, value := range claims {
+		switch name {
+		case "exp", "iat", "nbf", "jti":
+		default:
+			renewed[name] = value
+		}
+	}
+	renewed["exp"] = jwt.NewNumericDate(newExp)
+	setDefaultClaim(renewed, "auth_time", jwt.NewNumericDate(authTime))
+
+	token, err := j.signClaims(renewed)
+	if err != nil {
+		return
+	}
+
+	c.Header(j.session.Header, token)
+	if j.session.Cookie != nil {
+		cookie := *j.session.Cookie
+		cookie.Value = token
+		cookie.Expires = newExp
+		cookie.MaxAge = int(newExp.Sub(now) / time.Second)
+		http.SetCookie(c.Writer, &cookie)
+	}
+}
+
+// sessionStart returns the "auth_time" claim, or the "iat" claim if the
+// token has no "auth_time" claim.
+func sessionStart(claims jwt.MapClaims) (time.Time, bool) {
+	if t, ok := numericDateClaim(claims, "auth_time"); ok {
+		return t, true
+	}
+	return numericDateClaim(claims, "iat")
+}
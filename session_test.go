@@ -0,0 +1,166 @@
+package gincup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTSlidingSession(t *testing.T) {
+	j := NewJWT("secret", 1*time.Hour, WithSlidingSession(SlidingSession{
+		Window:      10 * time.Minute,
+		MaxLifetime: 8 * time.Hour,
+		Cookie:      &http.Cookie{Name: "access_token", Path: "/", HttpOnly: true},
+	}))
+
+	router := gin.New()
+	router.Use(j.MiddlewareWithSubject())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"subject": j.GetSubjectFromGinContext(c)})
+	})
+
+	request := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+
+	t.Run("fresh token is not renewed", func(t *testing.T) {
+		token, err := j.GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		w := request(token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-Renewed-Token"))
+	})
+
+	t.Run("token in renewal window is renewed", func(t *testing.T) {
+		authTime := now.Add(-2 * time.Hour)
+		token, err := GenerateTokenWithClaims(j, jwt.MapClaims{
+			"sub":       "test123",
+			"roles":     []string{"admin"},
+			"auth_time": authTime.Unix(),
+			"exp":       now.Add(5 * time.Minute).Unix(),
+		})
+		assert.NoError(t, err)
+
+		w := request(token)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		renewed := w.Header().Get("X-Renewed-Token")
+		assert.NotEmpty(t, renewed)
+
+		claims, err := j.parseToken(context.Background(), renewed)
+		assert.NoError(t, err)
+		assert.Equal(t, "test123", claims["sub"])
+		assert.Equal(t, []interface{}{"admin"}, claims["roles"])
+
+		renewedAuthTime, ok := numericDateClaim(claims, "auth_time")
+		assert.True(t, ok)
+		assert.Equal(t, authTime.Unix(), renewedAuthTime.Unix())
+
+		exp, err := claims.GetExpirationTime()
+		assert.NoError(t, err)
+		assert.WithinDuration(t, now.Add(time.Hour), exp.Time, 5*time.Second)
+
+		cookies := w.Result().Cookies()
+		assert.Len(t, cookies, 1)
+		assert.Equal(t, "access_token", cookies[0].Name)
+		assert.Equal(t, renewed, cookies[0].Value)
+		assert.True(t, cookies[0].HttpOnly)
+	})
+
+	t.Run("renewal is capped by max lifetime", func(t *testing.T) {
+		authTime := now.Add(-8*time.Hour + 30*time.Minute)
+		token, err := GenerateTokenWithClaims(j, jwt.MapClaims{
+			"sub":       "test123",
+			"auth_time": authTime.Unix(),
+			"exp":       now.Add(5 * time.Minute).Unix(),
+		})
+		assert.NoError(t, err)
+
+		w := request(token)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		claims, err := j.parseToken(context.Background(), w.Header().Get("X-Renewed-Token"))
+		assert.NoError(t, err)
+		exp, err := claims.GetExpirationTime()
+		assert.NoError(t, err)
+		assert.Equal(t, authTime.Add(8*time.Hour).Unix(), exp.Unix())
+	})
+
+	t.Run("session past max lifetime", func(t *testing.T) {
+		token, err := GenerateTokenWithClaims(j, jwt.MapClaims{
+			"sub":       "test123",
+			"auth_time": now.Add(-9 * time.Hour).Unix(),
+		})
+		assert.NoError(t, err)
+
+		w := request(token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"session expired"}`, w.Body.String())
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		assert.Panics(t, func() { WithSlidingSession(SlidingSession{MaxLifetime: time.Hour}) })
+		assert.Panics(t, func() { WithSlidingSession(SlidingSession{Window: time.Hour}) })
+	})
+
+	t.Run("auth_time only with a sliding session", func(t *testing.T) {
+		token, err := NewJWT("secret", 1*time.Hour).GenerateTokenAndSetSubject("test123")
+		assert.NoError(t, err)
+
+		claims, err := j.parseToken(context.Background(), token)
+		assert.NoError(t, err)
+		assert.NotContains(t, claims, "auth_time")
+	})
+}
+
+func TestJWTSlidingSessionWithRefresher(t *testing.T) {
+	ctx := context.Background()
+	loginTime := time.Now()
+	now := loginTime
+	j := NewJWT("secret", 5*time.Minute,
+		WithTimeFunc(func() time.Time { return now }),
+		WithSlidingSession(SlidingSession{
+			Window:      1 * time.Minute,
+			MaxLifetime: 10 * time.Minute,
+		}),
+	)
+	r := NewRefresher(j, NewMemoryRefreshTokenStore(), 24*time.Hour)
+
+	pair, err := r.IssueTokenPair(ctx, "test123")
+	assert.NoError(t, err)
+
+	t.Run("refresh keeps the login time", func(t *testing.T) {
+		now = loginTime.Add(4 * time.Minute)
+
+		next, err := r.Refresh(ctx, pair.RefreshToken)
+		assert.NoError(t, err)
+
+		claims, err := j.parseToken(ctx, next.AccessToken)
+		assert.NoError(t, err)
+		authTime, ok := numericDateClaim(claims, "auth_time")
+		assert.True(t, ok)
+		assert.Equal(t, loginTime.Unix(), authTime.Unix())
+
+		pair = next
+	})
+
+	t.Run("refresh after max lifetime", func(t *testing.T) {
+		now = loginTime.Add(20 * time.Minute)
+
+		_, err := r.Refresh(ctx, pair.RefreshToken)
+		assert.ErrorIs(t, err, ErrJWTSessionExpired)
+	})
+}
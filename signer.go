@@ -0,0 +1,116 @@
+package gincup
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/asn1"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signerMethod is a signing method that signs with a crypto.Signer, such as
+// a KMS or HSM client, and verifies with the standard signing method.
+type signerMethod struct {
+	jwt.SigningMethod
+	hash crypto.Hash
+	// keySize is the size of the R and S values of ECDSA signatures
+	keySize int
+}
+
+// newSignerMethod returns the signing method of a crypto.Signer.
+func newSignerMethod(signer crypto.Signer) (*signerMethod, error) {
+	method, err := signingMethodForPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	m := &signerMethod{SigningMethod: method}
+	switch method {
+	case jwt.SigningMethodRS256:
+		m.hash = crypto.SHA256
+	case jwt.SigningMethodES256:
+		m.hash, m.keySize = crypto.SHA256, 32
+	case jwt.SigningMethodES384:
+		m.hash, m.keySize = crypto.SHA384, 48
+	case jwt.SigningMethodES512:
+		m.hash, m.keySize = crypto.SHA512, 66
+	}
+	return m, nil
+}
+
+// Sign implements the jwt.SigningMethod interface.
+func (m *signerMethod) Sign(signingString string, key interface{}) ([]byte, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, jwt.ErrInvalidKeyType
+	}
+
+	// Ed25519 signs the message itself
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, []byte(signingString), crypto.Hash(0))
+	}
+
+	h := m.hash.New()
+	h.Write([]byte(signingString))
+	sig, err := signer.Sign(rand.Reader, h.Sum(nil), m.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		return ecdsaSignatureToJWS(sig, m.keySize)
+	}
+	return sig, nil
+}
+
+// ecdsaSignatureToJWS converts an ASN.1 ECDSA signature to the JWS format,
+// the concatenation of R and S as fixed size big-endian integers.
+func ecdsaSignatureToJWS(sig []byte, keySize int) ([]byte, error) {
+	var parsed struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(sig, &parsed)
+	if err != nil || len(rest) > 0 {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+
+	out := make([]byte, 2*keySize)
+	parsed.R.FillBytes(out[:keySize])
+	parsed.S.FillBytes(out[keySize:])
+	return out, nil
+}
+
+// newSignerKey creates a keyring entry from a crypto.Signer.
+func newSignerKey(id string, signer crypto.Signer) (*jwtKey, error) {
+	method, err := newSignerMethod(signer)
+	if err != nil {
+		return nil, err
+	}
+	return &jwtKey{id: id, method: method, signKey: signer, verifyKey: signer.Public()}, nil
+}
+
+// NewJWTWithSigner creates a new JWT instance that signs tokens with a
+// crypto.Signer, so that the private key can stay in a KMS or HSM.
+//
+// The public key of the signer must be an RSA (RS256), ECDSA (ES256, ES384
+// or ES512 depending on the curve) or Ed25519 (EdDSA) key. Tokens are
+// verified with the public key.
+//
+// If the signer is not supported, panic.
+// If the expire duration is less than or equal to 0, panic.
+func NewJWTWithSigner(signer crypto.Signer, expireDuration time.Duration, opts ...JWTOption) *JWT {
+	if expireDuration <= 0 {
+		panic("expire duration must be greater than 0")
+	}
+
+	key, err := newSignerKey("", signer)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return newJWTWithKey(key, expireDuration, opts)
+}
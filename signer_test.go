@@ -0,0 +1,109 @@
+package gincup
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// remoteSigner stands in for a KMS or HSM client: it only exposes the
+// crypto.Signer interface and counts the signatures.
+type remoteSigner struct {
+	key   crypto.Signer
+	calls atomic.Int32
+	err   error
+}
+
+func (s *remoteSigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s *remoteSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.calls.Add(1)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.key.Sign(rand, digest, opts)
+}
+
+func TestJWTWithSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	p521Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  crypto.Signer
+	}{
+		{"rsa", rsaKey},
+		{"ecdsa p-256", p256Key},
+		{"ecdsa p-521", p521Key},
+		{"ed25519", edKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &remoteSigner{key: tt.key}
+			j := NewJWTWithSigner(signer, 1*time.Hour)
+
+			token, err := j.GenerateTokenAndSetSubject("test123")
+			assert.NoError(t, err)
+			assert.Equal(t, int32(1), signer.calls.Load())
+
+			// the issuer and a verifier with the public key accept the token
+			sub, err := j.validateTokenAndGetSubject(token)
+			assert.NoError(t, err)
+			assert.Equal(t, "test123", sub)
+
+			sub, err = NewJWTVerifier(tt.key.Public()).validateTokenAndGetSubject(token)
+			assert.NoError(t, err)
+			assert.Equal(t, "test123", sub)
+
+			// the signer and the in-memory key produce the same kind of token
+			token, err = NewJWTWithPrivateKey(tt.key, 1*time.Hour).GenerateToken()
+			assert.NoError(t, err)
+			assert.NoError(t, j.validateToken(token))
+		})
+	}
+
+	t.Run("rotate to a signer", func(t *testing.T) {
+		signer := &remoteSigner{key: p256Key}
+		j := NewJWT("secret", 1*time.Hour)
+		assert.NoError(t, j.RotateKey("kms-1", signer))
+
+		token, err := j.GenerateToken()
+		assert.NoError(t, err)
+		assert.NoError(t, j.validateToken(token))
+		assert.Equal(t, "kms-1", j.JWKS().Keys[0].Kid)
+	})
+
+	t.Run("signer error", func(t *testing.T) {
+		signer := &remoteSigner{key: rsaKey, err: errors.New("kms unavailable")}
+		j := NewJWTWithSigner(signer, 1*time.Hour)
+
+		_, err := j.GenerateToken()
+		assert.Error(t, err)
+	})
+
+	t.Run("unsupported signer", func(t *testing.T) {
+		p224Key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+		assert.NoError(t, err)
+
+		assert.Panics(t, func() { NewJWTWithSigner(p224Key, 1*time.Hour) })
+	})
+}
@@ -0,0 +1,117 @@
+package gincup
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrJWTInsufficientUserAuthentication = errors.New("insufficient user authentication")
+)
+
+// InsufficientUserAuthenticationError is the error of a request rejected by
+// RequireStepUp. It tells the client how the user must authenticate again.
+type InsufficientUserAuthenticationError struct {
+	// ACRValues are the accepted authentication context classes.
+	ACRValues []string
+
+	// MaxAge is the maximum time since the user authenticated, or 0.
+	MaxAge time.Duration
+}
+
+// Error implements the error interface.
+func (e *InsufficientUserAuthenticationError) Error() string {
+	return ErrJWTInsufficientUserAuthentication.Error()
+}
+
+// Is reports whether the target is ErrJWTInsufficientUserAuthentication.
+func (e *InsufficientUserAuthenticationError) Is(target error) bool {
+	return target == ErrJWTInsufficientUserAuthentication
+}
+
+// StepUp is the authentication required by RequireStepUp.
+type StepUp struct {
+	// MaxAge is the maximum time since the user authenticated, according
+	// to the "auth_time" claim. 0 means any time.
+	//
+	// Tokens without an "auth_time" claim are rejected; "iat" is not used,
+	// since refreshed and exchanged tokens are issued long after the login.
+	// Refresher and TokenExchanger keep the "auth_time" of the login.
+	MaxAge time.Duration
+
+	// ACRValues are the accepted authentication context classes. The "acr"
+	// claim must be one of them. Empty means any class.
+	ACRValues []string
+
+	// AMR are the required authentication methods, such as "otp" or "hwk".
+	// The "amr" claim must contain every one of them.
+	AMR []string
+}
+
+// RequireStepUp is a middleware that requires a recent or strong enough
+// authentication of the user, as described by RFC 9470.
+//
+// It must be used after MiddlewareWithSubject or MiddlewareWithClaims.
+//
+// If the claims are not in the context, the middleware will return a 401 Unauthorized status.
+// If the authentication does not meet the requirement, the middleware will
+// return a 401 Unauthorized status with an *InsufficientUserAuthenticationError,
+// and the WWW-Authenticate header will carry the "acr_values" and "max_age"
+// the client must request when the user authenticates again.
+//
+// If the max age is negative, panic.
+func (j *JWT) RequireStepUp(stepUp StepUp) gin.HandlerFunc {
+	if stepUp.MaxAge < 0 {
+		panic("max age must not be negative")
+	}
+
+	err := &InsufficientUserAuthenticationError{
+		ACRValues: stepUp.ACRValues,
+		MaxAge:    stepUp.MaxAge,
+	}
+
+	return func(c *gin.Context) {
+		claims, ok := claimsFromGinContext(c)
+		if !ok {
+			j.abort(c, ErrJWTClaimsMissing)
+			return
+		}
+
+		if !j.meetsStepUp(claims, stepUp) {
+			j.abort(c, err)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// meetsStepUp reports whether the authentication of the user described by
+// the claims meets the requirement.
+func (j *JWT) meetsStepUp(claims jwt.MapClaims, stepUp StepUp) bool {
+	if stepUp.MaxAge > 0 {
+		authTime, ok := numericDateClaim(claims, "auth_time")
+		if !ok || j.now().Sub(authTime) > stepUp.MaxAge+j.leeway {
+			return false
+		}
+	}
+
+	if len(stepUp.ACRValues) > 0 {
+		acr, _ := claims["acr"].(string)
+		if !slices.Contains(stepUp.ACRValues, acr) {
+			return false
+		}
+	}
+
+	amr := stringsClaim(claims, "amr")
+	for _, method := range stepUp.AMR {
+		if !slices.Contains(amr, method) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package gincup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireStepUp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	j := NewJWT("secret", 1*time.Hour)
+
+	newRouter := func(j *JWT, stepUp StepUp) *gin.Engine {
+		router := gin.New()
+		router.POST("/payment", j.MiddlewareWithSubject(), j.RequireStepUp(stepUp), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return router
+	}
+	request := func(router *gin.Engine, claims map[string]any) *httptest.ResponseRecorder {
+		token, err := GenerateTokenWithClaims(j, claims)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("POST", "/payment", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	stepUp := StepUp{
+		MaxAge:    5 * time.Minute,
+		ACRValues: []string{"urn:example:mfa"},
+		AMR:       []string{"pwd", "otp"},
+	}
+	router := newRouter(j, stepUp)
+
+	t.Run("strong recent authentication", func(t *testing.T) {
+		w := request(router, map[string]any{
+			"sub":       "test123",
+			"acr":       "urn:example:mfa",
+			"amr":       []string{"pwd", "otp"},
+			"auth_time": time.Now().Unix(),
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	tests := map[string]map[string]any{
+		"old authentication": {
+			"sub":       "test123",
+			"acr":       "urn:example:mfa",
+			"amr":       []string{"pwd", "otp"},
+			"auth_time": time.Now().Add(-10 * time.Minute).Unix(),
+		},
+		"weak acr": {
+			"sub": "test123",
+			"acr": "urn:example:pwd",
+			"amr": []string{"pwd", "otp"},
+		},
+		"missing amr": {
+			"sub": "test123",
+			"acr": "urn:example:mfa",
+			"amr": []string{"pwd"},
+		},
+	}
+	for name, claims := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := request(router, claims)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Equal(t,
+				`Bearer error="insufficient_user_authentication", error_description="insufficient user authentication", acr_values="urn:example:mfa", max_age="300"`,
+				w.Header().Get("WWW-Authenticate"))
+			assert.JSONEq(t, `{"message":"insufficient user authentication"}`, w.Body.String())
+		})
+	}
+
+	t.Run("max age only", func(t *testing.T) {
+		router := newRouter(j, StepUp{MaxAge: time.Minute})
+		w := request(router, map[string]any{"sub": "test123", "auth_time": time.Now().Unix()})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("problem details", func(t *testing.T) {
+		j := NewJWT("secret", 1*time.Hour, WithErrorResponder(ProblemDetailsResponder{}))
+		router := newRouter(j, stepUp)
+		w := request(router, map[string]any{"sub": "test123"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		var problem map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "urn:example:mfa", problem["acr_values"])
+		assert.Equal(t, float64(300), problem["max_age"])
+	})
+
+	t.Run("refreshed and exchanged tokens keep the login time", func(t *testing.T) {
+		ctx := context.Background()
+		loginTime := time.Now()
+		now := loginTime
+		j := NewJWT("secret", 1*time.Hour, WithTimeFunc(func() time.Time { return now }))
+		router := newRouter(j, StepUp{MaxAge: 5 * time.Minute})
+		send := func(token string) int {
+			req := httptest.NewRequest("POST", "/payment", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		r := NewRefresher(j, NewMemoryRefreshTokenStore(), 24*time.Hour)
+		pair, err := r.IssueTokenPair(ctx, "test123")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, send(pair.AccessToken))
+
+		now = loginTime.Add(10 * time.Minute)
+		next, err := r.Refresh(ctx, pair.RefreshToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, send(next.AccessToken))
+
+		exchanger := NewTokenExchanger(j, func(ctx context.Context, req *TokenExchangeRequest) error { return nil })
+		actor, err := j.GenerateTokenAndSetSubject("support")
+		assert.NoError(t, err)
+		delegated, err := exchanger.Exchange(ctx, next.AccessToken, actor, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, send(delegated.AccessToken))
+	})
+
+	t.Run("without auth_time", func(t *testing.T) {
+		router := newRouter(j, StepUp{MaxAge: time.Hour})
+		w := request(router, map[string]any{"sub": "test123"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("without claims", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/test", j.RequireStepUp(stepUp), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("negative max age panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			j.RequireStepUp(StepUp{MaxAge: -time.Minute})
+		})
+	})
+}
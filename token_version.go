@@ -0,0 +1,91 @@
+package gincup
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenVersionLookup looks up the current token version of a subject.
+type TokenVersionLookup interface {
+	// TokenVersion returns the current token version of the subject.
+	TokenVersion(ctx context.Context, sub string) (uint64, error)
+}
+
+// TokenVersionFunc is a function that implements the TokenVersionLookup interface.
+type TokenVersionFunc func(ctx context.Context, sub string) (uint64, error)
+
+// TokenVersion implements the TokenVersionLookup interface.
+func (f TokenVersionFunc) TokenVersion(ctx context.Context, sub string) (uint64, error) {
+	return f(ctx, sub)
+}
+
+// setTokenVersion sets the current token version of the subject to the claims.
+func (j *JWT) setTokenVersion(claims jwt.MapClaims) error {
+	if j.tokenVersions == nil {
+		return nil
+	}
+	if _, ok := claims["ver"]; ok {
+		return nil
+	}
+
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return nil
+	}
+
+	version, err := j.tokenVersions.TokenVersion(context.Background(), sub)
+	if err != nil {
+		return err
+	}
+	claims["ver"] = version
+	return nil
+}
+
+// currentTokenVersion returns the current token version of the subject,
+// or 0 if no token version lookup is set.
+func (j *JWT) currentTokenVersion(ctx context.Context, sub string) (uint64, error) {
+	if j.tokenVersions == nil {
+		return 0, nil
+	}
+	return j.tokenVersions.TokenVersion(ctx, sub)
+}
+
+// checkTokenVersion rejects the claims of a token whose version is not the
+// current token version of its subject.
+//
+// A token without a "ver" claim has version 0.
+func (j *JWT) checkTokenVersion(ctx context.Context, claims jwt.MapClaims) error {
+	if j.tokenVersions == nil {
+		return nil
+	}
+
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return nil
+	}
+
+	var version uint64
+	if v, ok := claims["ver"]; ok {
+		n, ok := v.(json.Number)
+		if !ok {
+			return ErrJWTInvalidToken
+		}
+		parsed, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil {
+			return ErrJWTInvalidToken
+		}
+		version = parsed
+	}
+
+	current, err := j.tokenVersions.TokenVersion(ctx, sub)
+	if err != nil {
+		return err
+	}
+	if version != current {
+		return ErrJWTTokenRevoked
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package gincup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTTokenVersion(t *testing.T) {
+	var mu sync.Mutex
+	versions := map[string]uint64{"alice": 3}
+
+	lookup := TokenVersionFunc(func(ctx context.Context, sub string) (uint64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if sub == "broken" {
+			return 0, errors.New("database is down")
+		}
+		return versions[sub], nil
+	})
+
+	j := NewJWT("secret", 1*time.Hour, WithTokenVersion(lookup))
+
+	router := gin.New()
+	router.Use(j.MiddlewareWithSubject())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"subject": j.GetSubjectFromGinContext(c)})
+	})
+
+	request := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	aliceToken, err := j.GenerateTokenAndSetSubject("alice")
+	assert.NoError(t, err)
+	bobToken, err := j.GenerateTokenAndSetSubject("bob")
+	assert.NoError(t, err)
+
+	claims, err := j.parseToken(context.Background(), aliceToken)
+	assert.NoError(t, err)
+	assert.Equal(t, json.Number("3"), claims["ver"])
+
+	t.Run("current version", func(t *testing.T) {
+		w := request(aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subject":"alice"}`, w.Body.String())
+	})
+
+	t.Run("log out everywhere", func(t *testing.T) {
+		mu.Lock()
+		versions["alice"]++
+		mu.Unlock()
+
+		w := request(aliceToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"message":"token revoked"}`, w.Body.String())
+
+		// other subjects are not affected
+		w = request(bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// new tokens carry the new version
+		token, err := j.GenerateTokenAndSetSubject("alice")
+		assert.NoError(t, err)
+		w = request(token)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("refresh tokens issued before the bump", func(t *testing.T) {
+		r := NewRefresher(j, NewMemoryRefreshTokenStore(), 24*time.Hour)
+		ctx := context.Background()
+
+		pair, err := r.IssueTokenPair(ctx, "alice")
+		assert.NoError(t, err)
+		next, err := r.Refresh(ctx, pair.RefreshToken)
+		assert.NoError(t, err)
+
+		mu.Lock()
+		versions["alice"]++
+		mu.Unlock()
+
+		_, err = r.Refresh(ctx, next.RefreshToken)
+		assert.ErrorIs(t, err, ErrRefreshTokenRevoked)
+
+		// new logins work with the new version
+		pair, err = r.IssueTokenPair(ctx, "alice")
+		assert.NoError(t, err)
+		next, err = r.Refresh(ctx, pair.RefreshToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, request(next.AccessToken).Code)
+	})
+
+	t.Run("lookup error", func(t *testing.T) {
+		_, err := j.GenerateTokenAndSetSubject("broken")
+		assert.Error(t, err)
+	})
+}